@@ -1,17 +1,24 @@
+// Command git-auth checks that the caller can authenticate to GitHub over
+// SSH, and walks them through generating and registering a key if not.
 package main
 
 import (
 	"bufio"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 const githubSSHURL = "https://github.com/settings/keys"
+const githubHost = "github.com"
 
 func main() {
 	for {
@@ -30,14 +37,10 @@ func checkAuth() (bool, bool) {
 	printInfo("Checking GitHub SSH Authentication...")
 	runSpinner(2 * time.Second)
 
-	cmd := exec.Command("ssh", "-T", "git@github.com")
-	output, _ := cmd.CombinedOutput()
-	outStr := string(output)
-
-	// ✅ Success detection (GitHub returns exit code 1 on success)
-	if strings.Contains(outStr, "successfully authenticated") ||
-		(strings.Contains(outStr, "Hi ") && strings.Contains(outStr, "GitHub")) {
-
+	// ✅ Success detection: GitHub grants public-key auth even though it
+	// refuses a shell, so a successful Dial already proves the key is
+	// trusted. No need to exec a shell and grep its banner.
+	if authenticated := dialGitHub(); authenticated {
 		printSuccess("Authenticated successfully with GitHub.")
 		return true, true
 	}
@@ -126,6 +129,60 @@ func runSpinner(duration time.Duration) {
 	fmt.Print("\r")
 }
 
+////////////////////////////////////////////////////////////
+// 🔑 Native SSH Dial to GitHub
+////////////////////////////////////////////////////////////
+
+func dialGitHub() bool {
+	if comment, ok := agentCertificateIdentity(); ok {
+		printInfo("Using certificate identity from ssh-agent: " + comment)
+	}
+
+	auth, err := sshclient.ResolveAuth(githubHost)
+	if err != nil {
+		return false
+	}
+
+	client, err := sshclient.Dial("git", githubHost, "22", auth...)
+	if err != nil {
+		return false
+	}
+	defer client.Close()
+
+	// GitHub's SSH banner identifies the account the key authenticated as,
+	// e.g. "SSH-2.0-babeld-...". Surface it for debugging.
+	printInfo("Server: " + string(client.ServerVersion()))
+	return true
+}
+
+// agentCertificateIdentity reports the comment of the first
+// certificate-backed identity loaded in ssh-agent, if any. sshclient's
+// agent auth method already offers every loaded identity (including
+// certificates) to the server automatically; this is purely for a more
+// informative status line.
+func agentCertificateIdentity() (string, bool) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return "", false
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+
+	identities, err := agent.NewClient(conn).List()
+	if err != nil {
+		return "", false
+	}
+	for _, id := range identities {
+		if strings.Contains(id.Format, "cert-v01@openssh.com") {
+			return id.Comment, true
+		}
+	}
+	return "", false
+}
+
 ////////////////////////////////////////////////////////////
 // 🔍 Local SSH Key Detection
 ////////////////////////////////////////////////////////////
@@ -0,0 +1,188 @@
+// Command sshx-ca is a small SSH certificate authority, modeled on the
+// Teleport/OpenSSH certificate workflow: it signs short-lived user
+// certificates instead of copying raw public keys into authorized_keys
+// the way sshx-cpy does. Three subcommands:
+//
+//	sshx-ca init                                   generate the CA keypair
+//	sshx-ca sign --principals alice,root --ttl 8h user.pub
+//	sshx-ca trust user@host[:port]                 trust the CA on a server
+//
+// The actual signing/trust logic lives in internal/sshca, shared with
+// sshx-cpy's --cert flag.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshca"
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+)
+
+const defaultPort = "22"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "init":
+		cmdInit()
+	case "sign":
+		cmdSign(os.Args[2:])
+	case "trust":
+		cmdTrust(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Println("Usage:")
+	fmt.Println("  sshx-ca init")
+	fmt.Println("  sshx-ca sign --principals alice,root --ttl 8h <user.pub>")
+	fmt.Println("  sshx-ca trust user@host[:port]")
+	os.Exit(1)
+}
+
+////////////////////////////////////////////////////////////
+// init
+////////////////////////////////////////////////////////////
+
+func cmdInit() {
+	if err := sshca.Init(); err != nil {
+		fail("CA init failed", err)
+	}
+	pubPath, _ := sshca.PublicKeyPath()
+	printSuccess("CA initialized: " + pubPath)
+	fmt.Println("  Trust it on a server with: sshx-ca trust user@host")
+}
+
+////////////////////////////////////////////////////////////
+// sign
+////////////////////////////////////////////////////////////
+
+func cmdSign(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	principalsFlag := fs.String("principals", "", "comma-separated list of principals (required)")
+	ttlFlag := fs.Duration("ttl", 8*time.Hour, "certificate validity")
+	fs.Parse(args)
+
+	if *principalsFlag == "" || fs.NArg() != 1 {
+		fmt.Println("Usage: sshx-ca sign --principals alice,root --ttl 8h <user.pub>")
+		os.Exit(1)
+	}
+
+	certPath, err := sshca.Sign(fs.Arg(0), strings.Split(*principalsFlag, ","), *ttlFlag)
+	if err != nil {
+		fail("Sign certificate failed", err)
+	}
+
+	printSuccess(fmt.Sprintf("Signed %s for principals [%s], valid %s", certPath, *principalsFlag, ttlFlag.String()))
+}
+
+////////////////////////////////////////////////////////////
+// trust
+////////////////////////////////////////////////////////////
+
+func cmdTrust(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: sshx-ca trust user@host[:port]")
+		os.Exit(1)
+	}
+
+	user, host, port := parseTarget(args[0])
+
+	// Resolve host against ~/.ssh/config Host aliases (HostName/Port).
+	// The CLI's "user@" is always explicit here, so it wins over any
+	// config User.
+	if cfgHost, _, cfgPort := sshclient.ResolveTarget(host); cfgHost != "" {
+		host = cfgHost
+		if port == defaultPort {
+			port = cfgPort
+		}
+	}
+
+	auth, err := sshclient.ResolveAuth(host)
+	if err != nil {
+		fail("No usable SSH credentials", err)
+	}
+	client, err := sshclient.Dial(user, host, port, auth...)
+	if err != nil {
+		fail("SSH connection failed", err)
+	}
+	defer client.Close()
+
+	printInfo("Installing CA trust on " + host + "...")
+	// No sudo escalation here: `trust` expects to be run as a user who
+	// can already write /etc/ssh (typically root), unlike sshx-cpy
+	// --cert, which runs a preflight to find that out first.
+	if err := sshca.InstallTrust(client, nil); err != nil {
+		fail("Install trust failed", err)
+	}
+
+	fmt.Print("Restart sshd to apply now? (y/n): ")
+	if readYesNo() {
+		if out, err := client.Output("systemctl restart sshd || service sshd restart"); err != nil {
+			fail("Restart failed: "+out, err)
+		}
+		printSuccess("sshd restarted")
+	} else {
+		printInfo("Skipped restart -- TrustedUserCAKeys takes effect on the next sshd restart/reload.")
+	}
+
+	printSuccess("CA trusted on " + host)
+}
+
+////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////
+
+// parseTarget splits "user@host[:port]" the same way sshx-cpy does.
+func parseTarget(input string) (user, host, port string) {
+	parts := strings.SplitN(input, "@", 2)
+	if len(parts) != 2 {
+		fail("Invalid format. Expected user@host[:port]", nil)
+	}
+	user = parts[0]
+	hostPort := parts[1]
+	port = defaultPort
+
+	if idx := strings.LastIndex(hostPort, ":"); idx >= 0 && !strings.Contains(hostPort[idx:], "]") {
+		if _, err := strconv.Atoi(hostPort[idx+1:]); err == nil {
+			host = hostPort[:idx]
+			port = hostPort[idx+1:]
+			return user, host, port
+		}
+	}
+	host = hostPort
+	return user, host, port
+}
+
+func readYesNo() bool {
+	var line string
+	fmt.Scanln(&line)
+	line = strings.ToLower(strings.TrimSpace(line))
+	return line == "y" || line == "yes"
+}
+
+func colorRed(s string) string   { return "\033[31m" + s + "\033[0m" }
+func colorGreen(s string) string { return "\033[32m" + s + "\033[0m" }
+func colorCyan(s string) string  { return "\033[36m" + s + "\033[0m" }
+
+func printSuccess(msg string) { fmt.Println(colorGreen("✔ " + msg)) }
+func printInfo(msg string)    { fmt.Println(colorCyan("➜ " + msg)) }
+
+func fail(msg string, err error) {
+	if err != nil {
+		fmt.Println(colorRed(fmt.Sprintf("✖ %s: %v", msg, err)))
+	} else {
+		fmt.Println(colorRed("✖ " + msg))
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,404 @@
+// Command sshx-cpy installs the caller's SSH public key on a remote host
+// for passwordless login, either by appending it to authorized_keys or,
+// with --cert, by signing it through the sshx-ca CA and trusting that CA
+// on the server instead. A pre-flight check (see preflight.go) runs
+// first and gates any sudo-requiring step.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshca"
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// certTTL is how long a certificate minted by `sshx-cpy --cert` is valid.
+const certTTL = 24 * time.Hour
+
+const defaultPort = "22"
+
+func main() {
+	var useCert, jsonOut bool
+	var rest []string
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "--cert":
+			useCert = true
+		case "--json":
+			jsonOut = true
+		default:
+			rest = append(rest, a)
+		}
+	}
+	if len(rest) != 1 {
+		printError("Usage: sshx-cpy [--cert] [--json] user@host[:port]")
+		os.Exit(1)
+	}
+
+	userHost, port, err := parseTarget(rest[0])
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	user, host := splitUserHost(userHost)
+
+	// Resolve host against ~/.ssh/config Host aliases (HostName/Port).
+	// The CLI's "user@" is always explicit here, so it wins over any
+	// config User.
+	if cfgHost, _, cfgPort := sshclient.ResolveTarget(host); cfgHost != "" {
+		host = cfgHost
+		if port == defaultPort {
+			port = cfgPort
+		}
+	}
+
+	report, err := runPreflight(user, host, port)
+	if err != nil {
+		printError("Pre-flight check failed: " + err.Error())
+		os.Exit(1)
+	}
+	if jsonOut {
+		printPreflightJSON(report)
+	} else {
+		printPreflightHuman(report)
+	}
+
+	keyPath, err := detectPrivateKey()
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	printInfo("Using private key: " + keyPath)
+
+	pubKey, err := getPublicKey(keyPath)
+	if err != nil {
+		printError("Failed to extract public key: " + err.Error())
+		os.Exit(1)
+	}
+
+	if useCert {
+		runCertFlow(user, host, port, keyPath, pubKey, report)
+		return
+	}
+
+	printInfo(fmt.Sprintf("Installing key on %s (Port: %s)...", userHost, port))
+	if err := installKey(user, host, port, pubKey); err != nil {
+		printError("Failed to install key: " + err.Error())
+		os.Exit(1)
+	}
+
+	printInfo("Verifying passwordless login...")
+	if verifyLogin(user, host, port) {
+		printSuccess("Passwordless SSH enabled successfully!")
+		fmt.Printf("\nTest with:\n  ssh -p %s %s\n", port, userHost)
+	} else {
+		printError("Verification failed. Password may still be required.")
+		os.Exit(1)
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Certificate flow (--cert): sign the key with sshx-ca and trust it on
+// the server, instead of appending to authorized_keys.
+////////////////////////////////////////////////////////////
+
+func runCertFlow(user, host, port, keyPath, pubKey string, report *PreflightReport) {
+	if !sshca.Initialized() {
+		printInfo("No CA found, initializing one at ~/.config/sshx/ca...")
+		if err := sshca.Init(); err != nil {
+			printError("CA init failed: " + err.Error())
+			os.Exit(1)
+		}
+	}
+
+	pubPath := keyPath + ".pub"
+	if _, err := os.Stat(pubPath); os.IsNotExist(err) {
+		if err := os.WriteFile(pubPath, []byte(pubKey+"\n"), 0644); err != nil {
+			printError("Failed to write public key: " + err.Error())
+			os.Exit(1)
+		}
+	}
+
+	printInfo(fmt.Sprintf("Signing %s for principal %q (ttl %s)...", pubPath, user, certTTL))
+	certPath, err := sshca.Sign(pubPath, []string{user}, certTTL)
+	if err != nil {
+		printError("Certificate signing failed: " + err.Error())
+		os.Exit(1)
+	}
+
+	client, err := sshclient.Dial(user, host, port, authMethods(user, host)...)
+	if err != nil {
+		printError("SSH connection failed: " + err.Error())
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	// Installing CA trust writes under /etc/ssh; plain authorized_keys
+	// installs stay inside $HOME and don't need elevated perms. Reuse
+	// this same connection for the escalation so a cached password
+	// actually carries over to the privileged writes below.
+	escalation, err := escalateSudo(client, user, host, report)
+	if err != nil {
+		printError("sudo escalation failed: " + err.Error())
+		os.Exit(1)
+	}
+
+	printInfo("Installing CA trust on " + host + "...")
+	if err := sshca.InstallTrust(client, escalation); err != nil {
+		printError("Install trust failed: " + err.Error())
+		os.Exit(1)
+	}
+	printInfo("Reload sshd on the server for TrustedUserCAKeys to take effect (sshx-ca trust can do this for you).")
+
+	printInfo("Verifying login with the signed certificate...")
+	if verifyLoginCert(user, host, port, keyPath, certPath) {
+		printSuccess("Certificate-based SSH enabled successfully!")
+		fmt.Printf("\nTest with:\n  ssh -p %s -i %s -o CertificateFile=%s %s@%s\n", port, keyPath, certPath, user, host)
+	} else {
+		printError("Verification failed -- has sshd been restarted since trust was installed?")
+		os.Exit(1)
+	}
+}
+
+func verifyLoginCert(user, host, port, keyPath, certPath string) bool {
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return false
+	}
+	keySigner, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return false
+	}
+	certSigner, err := sshca.LoadCertSigner(certPath, keySigner)
+	if err != nil {
+		return false
+	}
+
+	client, err := sshclient.Dial(user, host, port, ssh.PublicKeys(certSigner))
+	if err != nil {
+		return false
+	}
+	defer client.Close()
+
+	return client.Run("exit") == nil
+}
+
+////////////////////////////////////////////////////////////
+// Target Parsing (IPv4 / IPv6 safe)
+////////////////////////////////////////////////////////////
+
+func parseTarget(input string) (string, string, error) {
+
+	if !strings.Contains(input, "@") {
+		return "", "", errors.New("Invalid format. Expected user@host[:port]")
+	}
+
+	userHost := input
+	port := defaultPort
+
+	// Handle IPv6 [host]:port
+	if strings.Contains(input, "]") {
+		host, p, err := net.SplitHostPort(input)
+		if err == nil {
+			return host, p, nil
+		}
+		return input, defaultPort, nil
+	}
+
+	// Handle normal host:port
+	if strings.Count(input, ":") == 1 {
+		host, p, err := net.SplitHostPort(input)
+		if err == nil {
+			return host, p, nil
+		}
+	}
+
+	return userHost, port, nil
+}
+
+// splitUserHost splits a "user@host" string (as always returned by
+// parseTarget) into its two parts.
+func splitUserHost(userHost string) (user, host string) {
+	parts := strings.SplitN(userHost, "@", 2)
+	return parts[0], parts[1]
+}
+
+////////////////////////////////////////////////////////////
+// Detect Private Key
+////////////////////////////////////////////////////////////
+
+func detectPrivateKey() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	sshDir := filepath.Join(home, ".ssh")
+
+	candidates := []string{
+		"id_ed25519",
+		"id_rsa",
+	}
+
+	for _, name := range candidates {
+		path := filepath.Join(sshDir, name)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", errors.New("No private SSH key found in ~/.ssh/")
+}
+
+////////////////////////////////////////////////////////////
+// Extract Public Key
+////////////////////////////////////////////////////////////
+
+func getPublicKey(privatePath string) (string, error) {
+
+	pubPath := privatePath + ".pub"
+
+	if data, err := os.ReadFile(pubPath); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	cmd := exec.Command("ssh-keygen", "-y", "-f", privatePath)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+////////////////////////////////////////////////////////////
+// Install Key (SFTP, no remote shell one-liner)
+////////////////////////////////////////////////////////////
+
+func installKey(user, host, port, pubKey string) error {
+	client, err := sshclient.Dial(user, host, port, authMethods(user, host)...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sc, err := client.SFTP()
+	if err != nil {
+		return fmt.Errorf("open sftp: %w", err)
+	}
+	defer sc.Close()
+
+	if err := sc.MkdirAll(".ssh"); err != nil {
+		return fmt.Errorf("mkdir ~/.ssh: %w", err)
+	}
+	sc.Chmod(".ssh", 0700)
+
+	var existing string
+	if f, err := sc.Open(".ssh/authorized_keys"); err == nil {
+		data, _ := io.ReadAll(f)
+		f.Close()
+		existing = string(data)
+	}
+
+	if keyAlreadyInstalled(existing, pubKey) {
+		return nil
+	}
+
+	f, err := sc.OpenFile(".ssh/authorized_keys", os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return fmt.Errorf("open authorized_keys: %w", err)
+	}
+	defer f.Close()
+
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		if _, err := f.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Write([]byte(pubKey + "\n")); err != nil {
+		return err
+	}
+
+	return sc.Chmod(".ssh/authorized_keys", 0600)
+}
+
+// keyAlreadyInstalled diffs pubKey against authorized_keys line-by-line
+// instead of appending it blindly.
+func keyAlreadyInstalled(authorizedKeys, pubKey string) bool {
+	pubKey = strings.TrimSpace(pubKey)
+	for _, line := range strings.Split(authorizedKeys, "\n") {
+		if strings.TrimSpace(line) == pubKey {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////
+// Verify Passwordless
+////////////////////////////////////////////////////////////
+
+func verifyLogin(user, host, port string) bool {
+	auth, err := sshclient.ResolveAuth(host)
+	if err != nil {
+		return false
+	}
+
+	client, err := sshclient.Dial(user, host, port, auth...)
+	if err != nil {
+		return false
+	}
+	defer client.Close()
+
+	return client.Run("exit") == nil
+}
+
+////////////////////////////////////////////////////////////
+// Auth Methods (agent + keys, falling back to an interactive password)
+////////////////////////////////////////////////////////////
+
+func authMethods(user, host string) []ssh.AuthMethod {
+	methods, _ := sshclient.ResolveAuth(host)
+	methods = append(methods, ssh.PasswordCallback(func() (string, error) {
+		return promptPassword(user, host)
+	}))
+	return methods
+}
+
+func promptPassword(user, host string) (string, error) {
+	fmt.Printf("%s@%s's password: ", user, host)
+	raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+////////////////////////////////////////////////////////////
+// Colored Output
+////////////////////////////////////////////////////////////
+
+func colorRed(s string) string    { return "\033[31m" + s + "\033[0m" }
+func colorGreen(s string) string  { return "\033[32m" + s + "\033[0m" }
+func colorYellow(s string) string { return "\033[33m" + s + "\033[0m" }
+func colorCyan(s string) string   { return "\033[36m" + s + "\033[0m" }
+
+func printSuccess(msg string) { fmt.Println(colorGreen("✔ " + msg)) }
+func printError(msg string)   { fmt.Println(colorRed("✖ " + msg)) }
+func printWarning(msg string) { fmt.Println(colorYellow("⚠ " + msg)) }
+func printInfo(msg string)    { fmt.Println(colorCyan("➜ " + msg)) }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+)
+
+// clockSkewWarnThreshold is how far the remote clock may drift from ours
+// before we flag it -- a couple of minutes is normal NTP jitter, more than
+// that usually means cert/TrustedUserCAKeys validity windows will be wrong.
+const clockSkewWarnThreshold = 5 * time.Minute
+
+// PreflightReport summarizes what sshx-cpy learned about the target before
+// touching it, so a human (or --json caller) can decide whether to proceed.
+type PreflightReport struct {
+	User                string   `json:"user"`
+	IsRoot              bool     `json:"is_root"`
+	HasPasswordlessSudo bool     `json:"has_passwordless_sudo"`
+	SSHDVersion         string   `json:"sshd_version"`
+	ClockSkew           string   `json:"clock_skew"`
+	Warnings            []string `json:"warnings"`
+}
+
+// runPreflight dials user@host and gathers the checks described in
+// PreflightReport. It opens and closes its own connection -- like the rest
+// of sshx-cpy's steps, it doesn't try to share a client across the whole
+// run.
+func runPreflight(user, host, port string) (*PreflightReport, error) {
+	client, err := sshclient.Dial(user, host, port, authMethods(user, host)...)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer client.Close()
+
+	report := &PreflightReport{User: user, SSHDVersion: string(client.ServerVersion())}
+
+	whoami, err := client.Output("whoami")
+	if err != nil {
+		return nil, fmt.Errorf("whoami: %w", err)
+	}
+	report.IsRoot = strings.TrimSpace(whoami) == "root"
+
+	if !report.IsRoot {
+		if err := client.Run("sudo -n true"); err == nil {
+			report.HasPasswordlessSudo = true
+		} else {
+			report.Warnings = append(report.Warnings, "sudo requires a password (no passwordless rule for "+user+")")
+		}
+	}
+
+	if out, err := client.Output("date +%s"); err == nil {
+		if remoteUnix, convErr := strconv.ParseInt(strings.TrimSpace(out), 10, 64); convErr == nil {
+			skew := time.Since(time.Unix(remoteUnix, 0))
+			report.ClockSkew = skew.Round(time.Second).String()
+			if skew > clockSkewWarnThreshold || skew < -clockSkewWarnThreshold {
+				report.Warnings = append(report.Warnings, "clock skew exceeds "+clockSkewWarnThreshold.String()+": "+report.ClockSkew)
+			}
+		}
+	} else {
+		report.Warnings = append(report.Warnings, "could not read remote clock: "+err.Error())
+	}
+
+	return report, nil
+}
+
+func printPreflightHuman(r *PreflightReport) {
+	printInfo("Pre-flight check:")
+	fmt.Println("  User:", r.User, "(root:", r.IsRoot, ")")
+	fmt.Println("  Passwordless sudo:", r.HasPasswordlessSudo)
+	fmt.Println("  sshd version:", r.SSHDVersion)
+	fmt.Println("  Clock skew:", r.ClockSkew)
+	for _, w := range r.Warnings {
+		printWarning(w)
+	}
+}
+
+func printPreflightJSON(r *PreflightReport) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		printError("Failed to encode pre-flight report: " + err.Error())
+		return
+	}
+	fmt.Println(string(data))
+}
+
+////////////////////////////////////////////////////////////
+// Sudo escalation: cache a password through sudo -S, never the command line
+////////////////////////////////////////////////////////////
+
+// escalateSudo decides how client's caller should run privileged commands
+// as user on host, based on report, and returns the resulting escalation
+// (nil if report.IsRoot -- nothing to escalate).
+//
+// When passwordless sudo isn't available, it prompts once and verifies
+// the password with a harmless `sudo -S true` over client -- the same
+// connection the returned Escalation's later RunSudo/CopySudo calls reuse,
+// so the cached credential actually carries over instead of priming a
+// connection that gets thrown away.
+func escalateSudo(client *sshclient.Client, user, host string, report *PreflightReport) (*sshclient.Escalation, error) {
+	if report.IsRoot {
+		return nil, nil
+	}
+	if report.HasPasswordlessSudo {
+		return &sshclient.Escalation{}, nil
+	}
+
+	printInfo("Target needs sudo for " + user + " to install CA trust -- caching credentials...")
+	password, err := promptPassword(user+" (sudo)", host)
+	if err != nil {
+		return nil, err
+	}
+	escalation := &sshclient.Escalation{Password: password}
+
+	if err := client.RunSudo(escalation, "true"); err != nil {
+		return nil, fmt.Errorf("sudo rejected the password: %w", err)
+	}
+	return escalation, nil
+}
@@ -1,3 +1,5 @@
+// Command sshx-reset cleans stale junk (*.old, *.tmp, *.bak, known_hosts)
+// out of ~/.ssh while leaving the caller's identity keys untouched.
 package main
 
 import (
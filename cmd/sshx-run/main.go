@@ -0,0 +1,516 @@
+// Command sshx-run fans a single command (or script, or file push/pull)
+// out to every host in an inventory, concurrently, reusing the shared
+// internal/sshclient transport. It is the superset of the single-host
+// tools in this module: where sshx-cpy/sshx-stream/git-auth each talk to
+// one target, sshx-run talks to all of them and reports a combined
+// result.
+//
+// The inventory format is this module's own line-oriented shape, not
+// YAML -- see parseInventory's doc comment for the grammar.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+	"golang.org/x/crypto/ssh"
+)
+
+// Host is one inventory entry, after group/default merging.
+type Host struct {
+	Name     string // hostname or IP
+	Group    string
+	User     string
+	Port     string // empty unless the inventory set one explicitly
+	Identity string // optional identity file override
+}
+
+// Result is what a single host produced, in the shape JSON-lines mode
+// emits verbatim.
+type Result struct {
+	Host       string `json:"host"`
+	RC         int    `json:"rc"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// action is what runAll executes against every host in the batch: a shell
+// command, a local script uploaded and run once per host, or a file
+// pushed to (or pulled from) every host.
+type action struct {
+	kind       string // "command", "script", "push", "pull"
+	command    string // kind == "command"
+	scriptPath string // kind == "script"
+	localPath  string // kind == "push" | "pull"
+	remotePath string // kind == "push" | "pull"
+}
+
+func main() {
+	var (
+		inventoryPath = flag.String("i", "", "inventory file (this module's own format, `hosts:`/`[group]` sections -- not YAML)")
+		concurrency   = flag.Int("c", 10, "max hosts to run concurrently")
+		timeout       = flag.Duration("t", 30*time.Second, "per-host timeout")
+		filter        = flag.String("P", "", "restrict to a group name or glob pattern over host names")
+		outputMode    = flag.String("o", "grid", "output mode: grid | json | line")
+		scriptPath    = flag.String("s", "", "local script file to upload and run on every host, instead of a command")
+		push          = flag.String("push", "", "local:remote -- copy a local file to every host")
+		pull          = flag.String("pull", "", "remote:localdir -- copy a remote file from every host into localdir/<host>/")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: sshx-run -i inventory.txt [-c N] [-t 30s] [-P group|pattern] [-o grid|json|line] <command>")
+		fmt.Fprintln(os.Stderr, "       sshx-run -i inventory.txt -s script.sh")
+		fmt.Fprintln(os.Stderr, "       sshx-run -i inventory.txt --push local:remote")
+		fmt.Fprintln(os.Stderr, "       sshx-run -i inventory.txt --pull remote:localdir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *inventoryPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	act, err := resolveAction(*scriptPath, *push, *pull, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	hosts, err := parseInventory(*inventoryPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ inventory:", err)
+		os.Exit(1)
+	}
+
+	hosts = filterHosts(hosts, *filter)
+	if len(hosts) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ no hosts matched filter", *filter)
+		os.Exit(1)
+	}
+
+	results := runAll(hosts, act, *concurrency, *timeout)
+
+	printResults(results, *outputMode)
+
+	var ok, failed, unreachable int
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			unreachable++
+		case r.RC != 0:
+			failed++
+		default:
+			ok++
+		}
+	}
+	fmt.Printf("\nok=%d failed=%d unreachable=%d\n", ok, failed, unreachable)
+	if failed+unreachable > 0 {
+		os.Exit(1)
+	}
+}
+
+// resolveAction picks exactly one of a command, a script upload, a push,
+// or a pull from the flags/positional args main() parsed, and rejects
+// any combination of more than one (or none at all).
+func resolveAction(scriptPath, push, pull string, args []string) (action, error) {
+	var candidates []action
+
+	if scriptPath != "" {
+		candidates = append(candidates, action{kind: "script", scriptPath: scriptPath})
+	}
+	if push != "" {
+		local, remote, ok := strings.Cut(push, ":")
+		if !ok {
+			return action{}, fmt.Errorf("--push wants local:remote, got %q", push)
+		}
+		candidates = append(candidates, action{kind: "push", localPath: local, remotePath: remote})
+	}
+	if pull != "" {
+		remote, local, ok := strings.Cut(pull, ":")
+		if !ok {
+			return action{}, fmt.Errorf("--pull wants remote:localdir, got %q", pull)
+		}
+		candidates = append(candidates, action{kind: "pull", remotePath: remote, localPath: local})
+	}
+	if len(args) > 0 {
+		candidates = append(candidates, action{kind: "command", command: strings.Join(args, " ")})
+	}
+
+	if len(candidates) != 1 {
+		return action{}, fmt.Errorf("exactly one of a command, -s, --push, or --pull is required (got %d)", len(candidates))
+	}
+	return candidates[0], nil
+}
+
+////////////////////////////////////////////////////////////
+// Execution
+////////////////////////////////////////////////////////////
+
+func runAll(hosts []Host, act action, concurrency int, timeout time.Duration) []Result {
+	sem := make(chan struct{}, concurrency)
+	results := make([]Result, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, h Host) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runOne(h, act, timeout)
+		}(i, h)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runOne(h Host, act action, timeout time.Duration) Result {
+	start := time.Now()
+	res := Result{Host: h.Name}
+
+	auth, err := hostAuth(h)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	done := make(chan struct{})
+	var client *sshclient.Client
+
+	host, port := resolveHostPort(h)
+
+	go func() {
+		client, err = sshclient.Dial(effectiveUser(h), host, port, auth...)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		res.Error = "connect timed out"
+		res.DurationMs = time.Since(start).Milliseconds()
+		// The dial may still succeed after we've given up on it; close
+		// the connection when it lands instead of leaking it.
+		go func() {
+			<-done
+			if client != nil {
+				client.Close()
+			}
+		}()
+		return res
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+		res.DurationMs = time.Since(start).Milliseconds()
+		return res
+	}
+	defer client.Close()
+
+	switch act.kind {
+	case "script":
+		res = runScript(res, client, act.scriptPath, timeout)
+	case "push":
+		res = runPush(res, client, act.localPath, act.remotePath)
+	case "pull":
+		res = runPull(res, client, h.Name, act.remotePath, act.localPath)
+	default:
+		res = runCommand(res, client, act.command, timeout)
+	}
+
+	res.DurationMs = time.Since(start).Milliseconds()
+	return res
+}
+
+// runCommand runs command over client, capturing stdout/stderr into res.
+func runCommand(res Result, client *sshclient.Client, command string, timeout time.Duration) Result {
+	sess, err := client.NewSession()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer sess.Close()
+
+	var stdout, stderr bytes.Buffer
+	sess.Stdout = &stdout
+	sess.Stderr = &stderr
+
+	runDone := make(chan error, 1)
+	go func() { runDone <- sess.Run(command) }()
+
+	select {
+	case runErr := <-runDone:
+		res.RC = exitCode(runErr)
+		res.Stdout = stdout.String()
+		res.Stderr = stderr.String()
+	case <-time.After(timeout):
+		sess.Close()
+		res.Error = "command timed out"
+	}
+	return res
+}
+
+// runScript uploads scriptPath to a per-host temp file over SFTP, runs it,
+// and removes it afterwards, reporting its exit status the same way
+// runCommand does for an inline command.
+func runScript(res Result, client *sshclient.Client, scriptPath string, timeout time.Duration) Result {
+	f, err := os.Open(scriptPath)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer f.Close()
+
+	remotePath := "/tmp/sshx-run-" + filepath.Base(scriptPath)
+	if err := client.Copy(f, remotePath); err != nil {
+		res.Error = fmt.Sprintf("upload script: %s", err)
+		return res
+	}
+
+	command := fmt.Sprintf("chmod +x %s && %s; rc=$?; rm -f %s; exit $rc", remotePath, remotePath, remotePath)
+	return runCommand(res, client, command, timeout)
+}
+
+// runPush copies localPath to remotePath on a single host.
+func runPush(res Result, client *sshclient.Client, localPath, remotePath string) Result {
+	f, err := os.Open(localPath)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer f.Close()
+
+	if err := client.Copy(f, remotePath); err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// runPull copies remotePath off a single host into localDir/<host>/.
+func runPull(res Result, client *sshclient.Client, host, remotePath, localDir string) Result {
+	dir := filepath.Join(localDir, host)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	f, err := os.Create(filepath.Join(dir, filepath.Base(remotePath)))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer f.Close()
+
+	if err := client.CopyFrom(f, remotePath); err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(interface{ ExitStatus() int }); ok {
+		return exitErr.ExitStatus()
+	}
+	return 1
+}
+
+// hostAuth resolves auth methods for h, honoring its per-host identity
+// override (tried first) ahead of the usual agent/~/.ssh resolution.
+func hostAuth(h Host) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if h.Identity != "" {
+		if data, err := os.ReadFile(expandHome(h.Identity)); err == nil {
+			if signer, err := ssh.ParsePrivateKey(data); err == nil {
+				methods = append(methods, ssh.PublicKeys(signer))
+			}
+		}
+	}
+
+	rest, err := sshclient.ResolveAuth(h.Name)
+	if err != nil && len(methods) == 0 {
+		return nil, err
+	}
+	return append(methods, rest...), nil
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return home + strings.TrimPrefix(p, "~")
+		}
+	}
+	return p
+}
+
+func effectiveUser(h Host) string {
+	if h.User != "" {
+		return h.User
+	}
+	if _, cfgUser, _ := sshclient.ResolveTarget(h.Name); cfgUser != "" {
+		return cfgUser
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "root"
+}
+
+// resolveHostPort applies ~/.ssh/config Host alias resolution (HostName,
+// Port) for an inventory entry, so an inventory line that names a config
+// alias rather than a raw hostname still connects to the right place.
+// An inventory-supplied port always wins over the config's -- h.Port is
+// only ever non-empty when the inventory set one explicitly.
+func resolveHostPort(h Host) (host, port string) {
+	cfgHost, _, cfgPort := sshclient.ResolveTarget(h.Name)
+	if h.Port != "" {
+		return cfgHost, h.Port
+	}
+	return cfgHost, cfgPort
+}
+
+////////////////////////////////////////////////////////////
+// Output
+////////////////////////////////////////////////////////////
+
+func printResults(results []Result, mode string) {
+	switch mode {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range results {
+			enc.Encode(r)
+		}
+	case "line":
+		for _, r := range results {
+			status := "ok"
+			if r.Error != "" {
+				status = "unreachable"
+			} else if r.RC != 0 {
+				status = "failed"
+			}
+			fmt.Printf("%-24s %-12s %dms\n", r.Host, status, r.DurationMs)
+		}
+	default: // grid
+		fmt.Printf("%-24s %-12s %8s  %s\n", "HOST", "STATUS", "ELAPSED", "FIRST ERROR LINE")
+		for _, r := range results {
+			status := "ok"
+			firstErr := ""
+			if r.Error != "" {
+				status = "unreachable"
+				firstErr = r.Error
+			} else if r.RC != 0 {
+				status = "failed"
+				firstErr = firstLine(r.Stderr)
+			}
+			fmt.Printf("%-24s %-12s %7dms  %s\n", r.Host, status, r.DurationMs, firstErr)
+		}
+	}
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+////////////////////////////////////////////////////////////
+// Inventory
+////////////////////////////////////////////////////////////
+
+// parseInventory reads this module's own line-oriented inventory format --
+// `[group]` headers (INI-like) over lines of `key=value` tokens, NOT YAML:
+//
+//	[webservers]
+//	web1.example.com user=deploy port=2222
+//	web2.example.com
+//
+//	[dbservers]
+//	db1.example.com user=postgres identity=~/.ssh/id_db
+//
+// A bare `hosts:` section (no group header) is supported too, matching
+// the flat list form: one host per line, same per-host `key=value`
+// overrides.
+func parseInventory(path string) ([]Host, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []Host
+	group := "ungrouped"
+
+	for _, rawLine := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			continue
+		}
+		if line == "hosts:" {
+			group = "ungrouped"
+			continue
+		}
+
+		fields := strings.Fields(line)
+		h := Host{Name: fields[0], Group: group}
+		for _, kv := range fields[1:] {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "user":
+				h.User = parts[1]
+			case "port":
+				h.Port = parts[1]
+			case "identity":
+				h.Identity = parts[1]
+			}
+		}
+		hosts = append(hosts, h)
+	}
+
+	return hosts, nil
+}
+
+// filterHosts keeps only hosts whose group matches pattern exactly, or
+// whose name matches pattern as a glob. An empty pattern keeps everything.
+func filterHosts(hosts []Host, pattern string) []Host {
+	if pattern == "" {
+		return hosts
+	}
+
+	var out []Host
+	for _, h := range hosts {
+		if h.Group == pattern {
+			out = append(out, h)
+			continue
+		}
+		if ok, _ := path.Match(pattern, h.Name); ok {
+			out = append(out, h)
+		}
+	}
+	return out
+}
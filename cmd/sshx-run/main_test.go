@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func writeInventory(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "inventory")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseInventoryGroupsAndOverrides(t *testing.T) {
+	path := writeInventory(t, `
+[webservers]
+web1.example.com user=deploy port=2222
+web2.example.com
+
+[dbservers]
+db1.example.com user=postgres identity=~/.ssh/id_db
+`)
+
+	hosts, err := parseInventory(path)
+	if err != nil {
+		t.Fatalf("parseInventory() = %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("got %d hosts, want 3: %+v", len(hosts), hosts)
+	}
+
+	want := []Host{
+		{Name: "web1.example.com", Group: "webservers", User: "deploy", Port: "2222"},
+		{Name: "web2.example.com", Group: "webservers"},
+		{Name: "db1.example.com", Group: "dbservers", User: "postgres", Identity: "~/.ssh/id_db"},
+	}
+	for i, h := range hosts {
+		if h != want[i] {
+			t.Errorf("host %d = %+v, want %+v", i, h, want[i])
+		}
+	}
+}
+
+func TestParseInventoryHostsSection(t *testing.T) {
+	path := writeInventory(t, "hosts:\none.example.com\ntwo.example.com port=2200\n")
+
+	hosts, err := parseInventory(path)
+	if err != nil {
+		t.Fatalf("parseInventory() = %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("got %d hosts, want 2", len(hosts))
+	}
+	if hosts[0].Group != "ungrouped" || hosts[0].Port != "" {
+		t.Errorf("host 0 = %+v, want ungrouped group and no explicit port", hosts[0])
+	}
+	if hosts[1].Port != "2200" {
+		t.Errorf("host 1 port = %q, want \"2200\"", hosts[1].Port)
+	}
+}
+
+func TestFilterHostsByGroupAndGlob(t *testing.T) {
+	hosts := []Host{
+		{Name: "web1.example.com", Group: "web"},
+		{Name: "web2.example.com", Group: "web"},
+		{Name: "db1.example.com", Group: "db"},
+	}
+
+	if got := filterHosts(hosts, ""); len(got) != 3 {
+		t.Errorf("empty pattern kept %d hosts, want 3", len(got))
+	}
+	if got := filterHosts(hosts, "db"); len(got) != 1 || got[0].Name != "db1.example.com" {
+		t.Errorf("filterHosts(db) = %+v", got)
+	}
+	if got := filterHosts(hosts, "web*"); len(got) != 2 {
+		t.Errorf("filterHosts(web*) = %+v, want 2 web hosts", got)
+	}
+}
+
+func TestResolveHostPortExplicitPortWins(t *testing.T) {
+	// With no ~/.ssh/config entry for this host, ResolveTarget falls back
+	// to host=h.Name, port="22" -- an inventory port=22 must still be
+	// attributed to the inventory, not silently mistaken for "unset".
+	h := Host{Name: "unconfigured.example.com", Port: "22"}
+	_, port := resolveHostPort(h)
+	if port != "22" {
+		t.Errorf("resolveHostPort() port = %q, want \"22\"", port)
+	}
+
+	h2 := Host{Name: "unconfigured.example.com", Port: "2222"}
+	_, port2 := resolveHostPort(h2)
+	if port2 != "2222" {
+		t.Errorf("resolveHostPort() port = %q, want \"2222\"", port2)
+	}
+}
+
+func TestResolveHostPortDefaultsWhenInventoryOmitsIt(t *testing.T) {
+	h := Host{Name: "unconfigured.example.com"}
+	_, port := resolveHostPort(h)
+	if port != "22" {
+		t.Errorf("resolveHostPort() port = %q, want the config/default \"22\"", port)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("exitCode(nil) = %d, want 0", got)
+	}
+	if got := exitCode(errors.New("boom")); got != 1 {
+		t.Errorf("exitCode(generic error) = %d, want 1", got)
+	}
+
+	exitErr := &ssh.ExitError{Waitmsg: ssh.Waitmsg{}}
+	if got := exitCode(exitErr); got != exitErr.ExitStatus() {
+		t.Errorf("exitCode(*ssh.ExitError) = %d, want %d", got, exitErr.ExitStatus())
+	}
+}
+
+func TestResolveActionRejectsZeroOrMultiple(t *testing.T) {
+	if _, err := resolveAction("", "", "", nil); err == nil {
+		t.Error("resolveAction() with nothing set = nil error, want a rejection")
+	}
+	if _, err := resolveAction("script.sh", "", "", []string{"echo", "hi"}); err == nil {
+		t.Error("resolveAction() with both -s and a command = nil error, want a rejection")
+	}
+}
+
+func TestResolveActionModes(t *testing.T) {
+	act, err := resolveAction("", "", "", []string{"echo", "hi"})
+	if err != nil || act.kind != "command" || act.command != "echo hi" {
+		t.Errorf("resolveAction(command) = %+v, %v", act, err)
+	}
+
+	act, err = resolveAction("script.sh", "", "", nil)
+	if err != nil || act.kind != "script" || act.scriptPath != "script.sh" {
+		t.Errorf("resolveAction(script) = %+v, %v", act, err)
+	}
+
+	act, err = resolveAction("", "local.txt:/remote/path", "", nil)
+	if err != nil || act.kind != "push" || act.localPath != "local.txt" || act.remotePath != "/remote/path" {
+		t.Errorf("resolveAction(push) = %+v, %v", act, err)
+	}
+
+	act, err = resolveAction("", "", "/remote/path:localdir", nil)
+	if err != nil || act.kind != "pull" || act.remotePath != "/remote/path" || act.localPath != "localdir" {
+		t.Errorf("resolveAction(pull) = %+v, %v", act, err)
+	}
+
+	if _, err := resolveAction("", "missing-colon", "", nil); err == nil {
+		t.Error("resolveAction(--push without a colon) = nil error, want a rejection")
+	}
+}
@@ -0,0 +1,353 @@
+// Command sshx-serve turns this module's one-shot tools into a durable,
+// auditable service: it's an embedded SSH server (via gliderlabs/ssh,
+// which itself wraps x/crypto/ssh) that exposes cleanup/stream/run as
+// remote subsystems authenticated against an authorized_keys file or
+// certificates signed by sshx-ca, records every session as an asciinema
+// cast, and can replay one back.
+//
+//	sshx-serve [-addr :2222] [-authorized-keys path] [-ca-pub path]
+//	sshx-serve replay <session-id>
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gliderssh "github.com/gliderlabs/ssh"
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshops"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if len(os.Args) != 3 {
+			fmt.Println("Usage: sshx-serve replay <session-id>")
+			os.Exit(1)
+		}
+		if err := replay(os.Args[2]); err != nil {
+			fmt.Println("❌ replay failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	addr := flag.String("addr", ":2222", "address to listen on")
+	authorizedKeysPath := flag.String("authorized-keys", defaultAuthorizedKeys(), "authorized_keys file")
+	caPubPath := flag.String("ca-pub", "", "trust certificates signed by this CA public key")
+	flag.Parse()
+
+	srv := &server{}
+	if err := srv.loadAuth(*authorizedKeysPath, *caPubPath); err != nil {
+		fmt.Println("❌ auth setup failed:", err)
+		os.Exit(1)
+	}
+
+	ssh := &gliderssh.Server{
+		Addr:             *addr,
+		Handler:          srv.handleSession,
+		PublicKeyHandler: srv.authorize,
+	}
+
+	fmt.Println("➜ sshx-serve listening on", *addr)
+	if err := ssh.ListenAndServe(); err != nil {
+		fmt.Println("❌ server stopped:", err)
+		os.Exit(1)
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Server / auth
+////////////////////////////////////////////////////////////
+
+type server struct {
+	authorizedKeys []gossh.PublicKey
+	certChecker    *gossh.CertChecker
+}
+
+func (s *server) loadAuth(authorizedKeysPath, caPubPath string) error {
+	if data, err := os.ReadFile(authorizedKeysPath); err == nil {
+		for len(data) > 0 {
+			key, _, _, rest, err := gossh.ParseAuthorizedKey(data)
+			if err != nil {
+				break
+			}
+			s.authorizedKeys = append(s.authorizedKeys, key)
+			data = rest
+		}
+	}
+
+	if caPubPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(caPubPath)
+	if err != nil {
+		return fmt.Errorf("read CA public key: %w", err)
+	}
+	caPub, _, _, _, err := gossh.ParseAuthorizedKey(data)
+	if err != nil {
+		return fmt.Errorf("parse CA public key: %w", err)
+	}
+	s.certChecker = &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caPub.Marshal())
+		},
+	}
+	return nil
+}
+
+func (s *server) authorize(ctx gliderssh.Context, key gliderssh.PublicKey) bool {
+	if cert, ok := key.(*gossh.Certificate); ok {
+		if s.certChecker == nil {
+			return false
+		}
+		if err := s.certChecker.CheckCert(ctx.User(), cert); err != nil {
+			return false
+		}
+		return true
+	}
+
+	for _, authorized := range s.authorizedKeys {
+		if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultAuthorizedKeys() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "authorized_keys")
+}
+
+////////////////////////////////////////////////////////////
+// Session handling: exec requests map straight to Go entrypoints, no
+// shell re-exec.
+////////////////////////////////////////////////////////////
+
+func (s *server) handleSession(sess gliderssh.Session) {
+	id := newSessionID()
+	rec, err := newRecorder(id, sess)
+	if err != nil {
+		fmt.Fprintln(sess, "❌ could not start session recording:", err)
+		sess.Exit(1)
+		return
+	}
+	defer rec.Close()
+
+	auditLog("connect", sess.User(), sess.RemoteAddr().String(), id, "")
+
+	out := io.MultiWriter(sess, rec)
+
+	cmd := sess.Command()
+	var err2 error
+	switch {
+	case len(cmd) == 0:
+		err2 = interactiveMenu(sess, out)
+	case cmd[0] == "cleanup":
+		err2 = sshops.Cleanup(out)
+	case cmd[0] == "stream" && len(cmd) >= 3 && cmd[1] == "push":
+		err2 = sshops.StreamPush(cmd[2], sess)
+	case cmd[0] == "stream" && len(cmd) >= 3 && cmd[1] == "pull":
+		err2 = sshops.StreamPull(cmd[2], out)
+	case cmd[0] == "run":
+		err2 = sshops.Run(cmd[1:], out, out)
+	default:
+		fmt.Fprintln(out, "unknown subsystem:", cmd[0])
+		err2 = fmt.Errorf("unknown subsystem %q", cmd[0])
+	}
+
+	auditLog("disconnect", sess.User(), sess.RemoteAddr().String(), id, strings.Join(cmd, " "))
+
+	if err2 != nil {
+		fmt.Fprintln(out, "❌", err2)
+		sess.Exit(1)
+		return
+	}
+	sess.Exit(0)
+}
+
+// interactiveMenu drives a bare `ssh host` connection (no exec request):
+// it allocates the requested PTY, then reads one subsystem invocation per
+// line instead of handing the client a real shell.
+func interactiveMenu(sess gliderssh.Session, out io.Writer) error {
+	if _, _, isPty := sess.Pty(); !isPty {
+		fmt.Fprintln(out, "sshx-serve exposes subsystems (cleanup, stream push/pull, run) -- connect with `ssh host <subsystem>`.")
+		return nil
+	}
+
+	fmt.Fprintln(out, "sshx-serve -- type 'cleanup', 'run <cmd>', or 'exit'")
+	scanner := bufio.NewScanner(sess)
+	for {
+		fmt.Fprint(out, "sshx> ")
+		if !scanner.Scan() {
+			return nil
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "exit":
+			return nil
+		case line == "cleanup":
+			sshops.Cleanup(out)
+		case strings.HasPrefix(line, "run "):
+			sshops.Run(strings.Fields(line)[1:], out, out)
+		default:
+			fmt.Fprintln(out, "unknown command:", line)
+		}
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+////////////////////////////////////////////////////////////
+// Recording: asciinema v2 cast + JSON-lines audit log
+////////////////////////////////////////////////////////////
+
+func recordingsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".local", "share", "sshx", "recordings")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+type castHeader struct {
+	Version int `json:"version"`
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+}
+
+type recorder struct {
+	f     *os.File
+	start time.Time
+}
+
+func newRecorder(id string, sess gliderssh.Session) (*recorder, error) {
+	dir, err := recordingsDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, id+".cast"))
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := 80, 24
+	if pty, _, isPty := sess.Pty(); isPty {
+		width, height = pty.Window.Width, pty.Window.Height
+	}
+	header, _ := json.Marshal(castHeader{Version: 2, Width: width, Height: height})
+	fmt.Fprintln(f, string(header))
+
+	return &recorder{f: f, start: time.Now()}, nil
+}
+
+// Write implements io.Writer, recording an "o" (output) event per call.
+func (r *recorder) Write(p []byte) (int, error) {
+	elapsed := time.Since(r.start).Seconds()
+	event := []interface{}{elapsed, "o", string(p)}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.f.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (r *recorder) Close() error {
+	return r.f.Close()
+}
+
+func auditLog(event, user, remoteAddr, sessionID, detail string) {
+	dir, err := recordingsDir()
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "audit.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	entry := map[string]string{
+		"ts":         time.Now().UTC().Format(time.RFC3339),
+		"event":      event,
+		"user":       user,
+		"remote":     remoteAddr,
+		"session_id": sessionID,
+		"detail":     detail,
+	}
+	data, _ := json.Marshal(entry)
+	f.Write(append(data, '\n'))
+}
+
+////////////////////////////////////////////////////////////
+// Replay
+////////////////////////////////////////////////////////////
+
+func replay(id string) error {
+	dir, err := recordingsDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(dir, id+".cast"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	if !scanner.Scan() {
+		return fmt.Errorf("empty recording")
+	}
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("parse header: %w", err)
+	}
+
+	var last float64
+	for scanner.Scan() {
+		var event []json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			continue
+		}
+		var ts float64
+		var kind, data string
+		json.Unmarshal(event[0], &ts)
+		json.Unmarshal(event[1], &kind)
+		json.Unmarshal(event[2], &data)
+
+		if d := ts - last; d > 0 {
+			time.Sleep(time.Duration(d * float64(time.Second)))
+		}
+		last = ts
+
+		if kind == "o" {
+			fmt.Print(data)
+		}
+	}
+	return scanner.Err()
+}
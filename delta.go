@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"os"
+)
+
+// DeltaBlockSize is the fixed block size used for the rsync-style rolling
+// checksum delta. Smaller blocks catch more matches on heavily edited
+// files at the cost of a bigger checksum table.
+const DeltaBlockSize = 4096
+
+// adlerMod is the modulus from the Adler-32 definition (the largest
+// prime below 2^16), used by rollingChecksum below.
+const adlerMod = 65521
+
+// rollingChecksum maintains the Adler-32 s1/s2 registers for a sliding
+// window so the window can be advanced by one byte in O(1), instead of
+// recomputing the checksum over the whole block at every offset. Sum
+// matches hash/adler32's Checksum for the same bytes.
+type rollingChecksum struct {
+	s1, s2 uint32
+	n      int64
+}
+
+// newRollingChecksum computes the initial registers for window.
+func newRollingChecksum(window []byte) *rollingChecksum {
+	r := &rollingChecksum{s1: 1, n: int64(len(window))}
+	for _, b := range window {
+		r.s1 = (r.s1 + uint32(b)) % adlerMod
+		r.s2 = (r.s2 + r.s1) % adlerMod
+	}
+	return r
+}
+
+// Sum returns the current Adler-32 value.
+func (r *rollingChecksum) Sum() uint32 {
+	return r.s2<<16 | r.s1
+}
+
+// Roll slides the window forward by one byte: out leaves at the trailing
+// edge, in enters at the leading edge. Both registers update from their
+// prior values alone, independent of the window size.
+func (r *rollingChecksum) Roll(out, in byte) {
+	s1 := (int64(r.s1) - int64(out) + int64(in)) % adlerMod
+	s1 = (s1 + adlerMod) % adlerMod
+
+	s2 := (int64(r.s2) - r.n*int64(out) + s1 - 1) % adlerMod
+	s2 = (s2 + adlerMod) % adlerMod
+
+	r.s1, r.s2 = uint32(s1), uint32(s2)
+}
+
+// literalFlushThreshold bounds how much unmatched data we buffer before
+// emitting it as a literal run, so a single large non-matching region
+// doesn't balloon memory use.
+const literalFlushThreshold = 16 * DeltaBlockSize
+
+// BlockSum is one (weak, strong) checksum pair for a fixed-size block of
+// the receiver's existing copy of a file. Len is almost always
+// DeltaBlockSize -- the exception is the last block of a file whose size
+// isn't an exact multiple of it, which is shorter.
+type BlockSum struct {
+	Index  int
+	Len    int
+	Weak   uint32
+	Strong [sha256.Size]byte
+}
+
+// computeBlockSums splits path into DeltaBlockSize blocks and returns the
+// rolling (adler32) and strong (sha256) checksum of each.
+func computeBlockSums(path string) ([]BlockSum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sums []BlockSum
+	buf := make([]byte, DeltaBlockSize)
+	for index := 0; ; index++ {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sums = append(sums, BlockSum{
+				Index:  index,
+				Len:    n,
+				Weak:   adler32.Checksum(buf[:n]),
+				Strong: sha256.Sum256(buf[:n]),
+			})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sums, nil
+}
+
+// writeBlockSums serializes a checksum table as "<index> <len> <weak>
+// <strong>" lines, terminated by a blank line.
+func writeBlockSums(w io.Writer, sums []BlockSum) error {
+	for _, s := range sums {
+		if _, err := fmt.Fprintf(w, "%d %d %08x %x\n", s.Index, s.Len, s.Weak, s.Strong); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// readBlockSums parses the format written by writeBlockSums.
+func readBlockSums(r io.Reader) ([]BlockSum, error) {
+	var sums []BlockSum
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		var s BlockSum
+		var strongHex string
+		if _, err := fmt.Sscanf(line, "%d %d %x %s", &s.Index, &s.Len, &s.Weak, &strongHex); err != nil {
+			return nil, fmt.Errorf("parse block sum line %q: %w", line, err)
+		}
+		strongBytes, err := hex.DecodeString(strongHex)
+		if err != nil || len(strongBytes) != sha256.Size {
+			return nil, fmt.Errorf("parse strong checksum %q: %w", strongHex, err)
+		}
+		copy(s.Strong[:], strongBytes)
+		sums = append(sums, s)
+	}
+	return sums, scanner.Err()
+}
+
+////////////////////////////////////////////////////////////
+// Sender side: diff a local file against a checksum table
+////////////////////////////////////////////////////////////
+
+// diffAgainstTable slides a 1-byte rolling window over path and emits an
+// instruction stream to w: "C <index>" to copy a matched block from the
+// receiver's existing copy, "D <len>\n<bytes>" for a literal run, and a
+// trailing "E" once the whole file has been covered. It returns how many
+// bytes were satisfied by copies vs. sent as literals.
+func diffAgainstTable(path string, table []BlockSum) (io.Reader, func() (matched, literal int64), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byWeak := make(map[uint32][]BlockSum, len(table))
+	for _, s := range table {
+		byWeak[s.Weak] = append(byWeak[s.Weak], s)
+	}
+
+	// tailLen is the length of the table's trailing short block, if the
+	// receiver's copy wasn't an exact multiple of DeltaBlockSize -- the
+	// one case a fixed-size rolling window can never match on its own,
+	// since it's the only block shorter than DeltaBlockSize.
+	var tailLen int
+	if n := len(table); n > 0 && table[n-1].Len < DeltaBlockSize {
+		tailLen = table[n-1].Len
+	}
+
+	pr, pw := io.Pipe()
+	var matched, literal int64
+
+	go func() {
+		w := bufio.NewWriter(pw)
+		var literalBuf []byte
+
+		flushLiteral := func() error {
+			if len(literalBuf) == 0 {
+				return nil
+			}
+			literal += int64(len(literalBuf))
+			if _, err := fmt.Fprintf(w, "D %d\n", len(literalBuf)); err != nil {
+				return err
+			}
+			if _, err := w.Write(literalBuf); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return err
+			}
+			literalBuf = literalBuf[:0]
+			return nil
+		}
+
+		i := 0
+		var roll *rollingChecksum
+		if len(data)-i >= DeltaBlockSize {
+			roll = newRollingChecksum(data[i : i+DeltaBlockSize])
+		}
+
+		for i < len(data) {
+			var window []byte
+			var weak uint32
+			tryMatch := false
+
+			switch {
+			case roll != nil:
+				window = data[i : i+DeltaBlockSize]
+				weak = roll.Sum()
+				tryMatch = true
+			case tailLen > 0 && len(data)-i == tailLen:
+				// The rolling window never forms over a span shorter
+				// than DeltaBlockSize, so the file's own trailing
+				// partial block needs a one-off direct comparison
+				// against the table's matching short block.
+				window = data[i:]
+				weak = adler32.Checksum(window)
+				tryMatch = true
+			}
+
+			if tryMatch {
+				if candidates, ok := byWeak[weak]; ok {
+					strong := sha256.Sum256(window)
+					matchedIdx := -1
+					for _, c := range candidates {
+						if c.Len == len(window) && c.Strong == strong {
+							matchedIdx = c.Index
+							break
+						}
+					}
+					if matchedIdx >= 0 {
+						if err := flushLiteral(); err != nil {
+							pw.CloseWithError(err)
+							return
+						}
+						matched += int64(len(window))
+						if _, err := fmt.Fprintf(w, "C %d\n", matchedIdx); err != nil {
+							pw.CloseWithError(err)
+							return
+						}
+						i += len(window)
+						roll = nil
+						if len(data)-i >= DeltaBlockSize {
+							roll = newRollingChecksum(data[i : i+DeltaBlockSize])
+						}
+						continue
+					}
+				}
+			}
+
+			// No match at this offset: emit the byte as a literal and
+			// slide the window forward one byte with an O(1) update
+			// (add the incoming byte, drop the outgoing one) instead of
+			// recomputing the checksum over the whole block.
+			literalBuf = append(literalBuf, data[i])
+			if roll != nil && i+DeltaBlockSize < len(data) {
+				roll.Roll(data[i], data[i+DeltaBlockSize])
+			} else {
+				roll = nil
+			}
+			i++
+			if len(literalBuf) >= literalFlushThreshold {
+				if err := flushLiteral(); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}
+
+		if err := flushLiteral(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		fmt.Fprintln(w, "E")
+		w.Flush()
+		pw.Close()
+	}()
+
+	return pr, func() (int64, int64) { return matched, literal }, nil
+}
+
+////////////////////////////////////////////////////////////
+// Receiver side: replay an instruction stream against the old copy
+////////////////////////////////////////////////////////////
+
+// applyInstructions reconstructs path from its own previous contents (for
+// "C" ops) plus the literal bytes in the stream (for "D" ops), writing to
+// a temp file and renaming over the original so a failed transfer never
+// corrupts the existing copy. It returns how many bytes were satisfied by
+// copies vs. literals, for progress reporting.
+func applyInstructions(path string, r io.Reader) (matched, literal int64, err error) {
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+
+	tmpPath := path + ".sshxnew"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			out.Close()
+			return matched, literal, fmt.Errorf("read instruction: %w", err)
+		}
+		line = line[:len(line)-1]
+
+		switch {
+		case line == "E":
+			out.Close()
+			return matched, literal, os.Rename(tmpPath, path)
+		case len(line) > 2 && line[0] == 'C':
+			var index int
+			if _, err := fmt.Sscanf(line, "C %d", &index); err != nil {
+				out.Close()
+				return matched, literal, fmt.Errorf("parse copy op %q: %w", line, err)
+			}
+			start := index * DeltaBlockSize
+			end := start + DeltaBlockSize
+			if end > len(old) {
+				end = len(old)
+			}
+			if start > len(old) {
+				out.Close()
+				return matched, literal, fmt.Errorf("copy op references block %d past end of old file", index)
+			}
+			if _, err := out.Write(old[start:end]); err != nil {
+				out.Close()
+				return matched, literal, err
+			}
+			matched += int64(end - start)
+		case len(line) > 2 && line[0] == 'D':
+			var n int
+			if _, err := fmt.Sscanf(line, "D %d", &n); err != nil {
+				out.Close()
+				return matched, literal, fmt.Errorf("parse data op %q: %w", line, err)
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(reader, buf); err != nil {
+				out.Close()
+				return matched, literal, err
+			}
+			reader.Discard(1) // trailing newline after the literal bytes
+			if _, err := out.Write(buf); err != nil {
+				out.Close()
+				return matched, literal, err
+			}
+			literal += int64(n)
+		default:
+			out.Close()
+			return matched, literal, fmt.Errorf("unknown instruction %q", line)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Remote worker entry points (invoked as `sshx-stream --flag <path>` over
+// an SSH session on the other end of a push/pull).
+////////////////////////////////////////////////////////////
+
+// cmdBlockSums writes the checksum table for an existing file to stdout,
+// or an empty table if it doesn't exist yet (signalling "send it all").
+func cmdBlockSums(path string) {
+	var sums []BlockSum
+	if _, err := os.Stat(path); err == nil {
+		var err error
+		sums, err = computeBlockSums(path)
+		if err != nil {
+			fatal("blocksums failed", err)
+		}
+	}
+	if err := writeBlockSums(os.Stdout, sums); err != nil {
+		fatal("write blocksums failed", err)
+	}
+}
+
+// cmdApplyDelta reads an instruction stream from stdin and reconstructs
+// path from it, printing a stats line the invoking side can parse.
+func cmdApplyDelta(path string) {
+	if dir := parentDir(path); dir != "" {
+		os.MkdirAll(dir, 0755)
+	}
+	matched, literal, err := applyInstructions(path, os.Stdin)
+	if err != nil {
+		fatal("apply-delta failed", err)
+	}
+	fmt.Printf("STATS matched=%d literal=%d\n", matched, literal)
+}
+
+// cmdGenDiff reads a checksum table (as written by writeBlockSums) from
+// stdin and streams an instruction stream for path to stdout.
+func cmdGenDiff(path string) {
+	table, err := readBlockSums(os.Stdin)
+	if err != nil {
+		fatal("gendiff: bad checksum table", err)
+	}
+	instrs, _, err := diffAgainstTable(path, table)
+	if err != nil {
+		fatal("gendiff failed", err)
+	}
+	if _, err := io.Copy(os.Stdout, instrs); err != nil {
+		fatal("gendiff stream failed", err)
+	}
+}
+
+func parentDir(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"hash/adler32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRandomFile(t *testing.T, path string, size int, seed int64) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func diffStats(t *testing.T, path string, table []BlockSum) (matched, literal int64, instrs []byte) {
+	t.Helper()
+	r, stats, err := diffAgainstTable(path, table)
+	if err != nil {
+		t.Fatalf("diffAgainstTable: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("read instruction stream: %v", err)
+	}
+	matched, literal = stats()
+	return matched, literal, buf.Bytes()
+}
+
+// An unmodified file, round-tripped against its own checksum table, should
+// match in full regardless of whether its size is a multiple of
+// DeltaBlockSize -- including a trailing partial block.
+func TestDiffAgainstTableUnmodifiedFile(t *testing.T) {
+	for _, size := range []int{0, 1, 100, DeltaBlockSize, DeltaBlockSize + 1, DeltaBlockSize*2 + 37} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "f")
+		writeRandomFile(t, path, size, 1)
+
+		table, err := computeBlockSums(path)
+		if err != nil {
+			t.Fatalf("computeBlockSums(size=%d): %v", size, err)
+		}
+		matched, literal, _ := diffStats(t, path, table)
+		if matched != int64(size) || literal != 0 {
+			t.Errorf("size=%d: matched=%d literal=%d, want matched=%d literal=0", size, matched, literal, size)
+		}
+	}
+}
+
+// A file with no prior copy (empty table) must be sent entirely as literals.
+func TestDiffAgainstTableNoPriorCopy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	writeRandomFile(t, path, 5000, 2)
+
+	matched, literal, _ := diffStats(t, path, nil)
+	if matched != 0 || literal != 5000 {
+		t.Errorf("matched=%d literal=%d, want matched=0 literal=5000", matched, literal)
+	}
+}
+
+// Changing a single byte inside one block should cost only that block as a
+// literal, with every other block -- including the trailing partial one --
+// still matched.
+func TestDiffAgainstTableSingleByteEdit(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old")
+	newPath := filepath.Join(dir, "new")
+
+	size := DeltaBlockSize*3 + 500
+	data := writeRandomFile(t, oldPath, size, 3)
+
+	edited := append([]byte(nil), data...)
+	edited[DeltaBlockSize+10] ^= 0xFF
+	if err := os.WriteFile(newPath, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := computeBlockSums(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, literal, _ := diffStats(t, newPath, table)
+	if literal == 0 {
+		t.Fatal("literal=0, want the edited block to be resent")
+	}
+	if matched+literal != int64(size) {
+		t.Errorf("matched+literal=%d, want %d", matched+literal, size)
+	}
+	// Only the one edited block's worth of data should need resending --
+	// the rest, including the tail, should still match.
+	if literal > DeltaBlockSize {
+		t.Errorf("literal=%d, want at most one block's worth resent", literal)
+	}
+}
+
+// computeBlockSums/diffAgainstTable round-trip through the wire format
+// (writeBlockSums/readBlockSums) exactly as the sender and receiver would
+// exchange it over a connection.
+func TestBlockSumsWireRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	writeRandomFile(t, path, DeltaBlockSize+250, 4)
+
+	table, err := computeBlockSums(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeBlockSums(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := readBlockSums(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(table) {
+		t.Fatalf("got %d blocks, want %d", len(parsed), len(table))
+	}
+	for i := range table {
+		if parsed[i] != table[i] {
+			t.Errorf("block %d = %+v, want %+v", i, parsed[i], table[i])
+		}
+	}
+
+	matched, literal, _ := diffStats(t, path, parsed)
+	if matched != int64(DeltaBlockSize+250) || literal != 0 {
+		t.Errorf("matched=%d literal=%d after wire round trip, want full match", matched, literal)
+	}
+}
+
+// applyInstructions should reconstruct an unmodified file byte-for-byte from
+// the instruction stream diffAgainstTable produces against it.
+func TestApplyInstructionsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	data := writeRandomFile(t, path, DeltaBlockSize+250, 5)
+
+	table, err := computeBlockSums(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, instrs := diffStats(t, path, table)
+
+	matched, literal, err := applyInstructions(path, bytes.NewReader(instrs))
+	if err != nil {
+		t.Fatalf("applyInstructions: %v", err)
+	}
+	if matched != int64(len(data)) || literal != 0 {
+		t.Errorf("matched=%d literal=%d, want matched=%d literal=0", matched, literal, len(data))
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("reconstructed file does not match the original")
+	}
+}
+
+// rollingChecksum's incremental update must agree with a from-scratch
+// Adler-32 computation at every offset as the window slides across the
+// file, not just at block boundaries.
+func TestRollingChecksumMatchesAdler32(t *testing.T) {
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(6)).Read(data)
+
+	const window = 64
+	roll := newRollingChecksum(data[:window])
+	for i := 0; i+window < len(data); i++ {
+		got := roll.Sum()
+		want := adler32.Checksum(data[i : i+window])
+		if got != want {
+			t.Fatalf("offset %d: rollingChecksum.Sum()=%x, want %x", i, got, want)
+		}
+		roll.Roll(data[i], data[i+window])
+	}
+}
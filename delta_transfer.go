@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+)
+
+// deltaPushTree mirrors localDir onto remotePath file-by-file using the
+// rolling-checksum delta protocol in delta.go instead of re-sending the
+// whole tree as a tar archive. It assumes the same sshx-stream binary is
+// reachable in $PATH on the remote end (it invokes itself there with
+// --blocksums/--apply-delta). `--full` still uses tarPush for trees where
+// that isn't true.
+func deltaPushTree(ctx context.Context, localDir, remotePath string, client *sshclient.Client, sshCmd SSHFunc) {
+	if _, err := sshCmd(fmt.Sprintf("mkdir -p \"%s\"", remotePath)); err != nil {
+		fatal("Remote mkdir failed", err)
+	}
+
+	var files []string
+	err := filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		fatal("Walk failed", err)
+	}
+
+	sem := make(chan struct{}, Workers)
+	var wg sync.WaitGroup
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(localFile string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pushFileDelta(ctx, client, sshCmd, localDir, localFile, remotePath)
+		}(f)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		fatal("Push cancelled", nil)
+	}
+	fmt.Println("✅ Push completed (delta)")
+}
+
+func pushFileDelta(ctx context.Context, client *sshclient.Client, sshCmd SSHFunc, localDir, localFile, remotePath string) {
+	relPath, err := filepath.Rel(localDir, localFile)
+	if err != nil {
+		fatal("Relative path failed", err)
+	}
+	remoteFile := remotePath + "/" + filepath.ToSlash(relPath)
+
+	if dir := filepath.ToSlash(filepath.Dir(relPath)); dir != "." {
+		if _, err := sshCmd(fmt.Sprintf("mkdir -p \"%s/%s\"", remotePath, dir)); err != nil {
+			fatal("Remote mkdir failed", err)
+		}
+	}
+
+	sumsSess, err := client.NewSession()
+	if err != nil {
+		fatal("Session failed", err)
+	}
+	var sumsOut bytes.Buffer
+	sumsSess.Stdout = &sumsOut
+	sumsErr := sumsSess.Run(fmt.Sprintf("sshx-stream --blocksums \"%s\"", remoteFile))
+	sumsSess.Close()
+
+	var table []BlockSum
+	if sumsErr == nil {
+		table, _ = readBlockSums(&sumsOut)
+	}
+
+	instrs, stats, err := diffAgainstTable(localFile, table)
+	if err != nil {
+		fatal(fmt.Sprintf("diff failed for %s", relPath), err)
+	}
+
+	applySess, err := client.NewSession()
+	if err != nil {
+		fatal("Session failed", err)
+	}
+	applySess.Stdin = instrs
+	var applyOut bytes.Buffer
+	applySess.Stdout = &applyOut
+	if err := applySess.Run(fmt.Sprintf("sshx-stream --apply-delta \"%s\"", remoteFile)); err != nil {
+		applySess.Close()
+		fatal(fmt.Sprintf("apply-delta failed for %s", relPath), err)
+	}
+	applySess.Close()
+
+	matched, literal := stats()
+	fmt.Printf("📄 %-40s matched=%d literal=%d\n", relPath, matched, literal)
+}
+
+// deltaPullTree mirrors remotePath onto localDir file-by-file, the mirror
+// image of deltaPushTree: the checksum table is computed locally (over
+// our own previous copy) and sent to the remote, which streams back only
+// the blocks we're missing.
+func deltaPullTree(ctx context.Context, remotePath, localDir string, client *sshclient.Client, sshCmd SSHFunc) {
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		fatal("Cannot create local directory", err)
+	}
+
+	listing, err := sshCmd(fmt.Sprintf("cd \"%s\" && find . -type f", remotePath))
+	if err != nil {
+		fatal("Remote listing failed", err)
+	}
+
+	var relPaths []string
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "./"))
+		if line != "" {
+			relPaths = append(relPaths, line)
+		}
+	}
+
+	sem := make(chan struct{}, Workers)
+	var wg sync.WaitGroup
+	for _, rel := range relPaths {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(rel string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pullFileDelta(client, remotePath, localDir, rel)
+		}(rel)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		fatal("Pull cancelled", nil)
+	}
+	fmt.Println("✅ Pull completed (delta)")
+}
+
+func pullFileDelta(client *sshclient.Client, remotePath, localDir, relPath string) {
+	localFile := filepath.Join(localDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(localFile), 0755); err != nil {
+		fatal("Cannot create local directory", err)
+	}
+
+	var table []BlockSum
+	if _, err := os.Stat(localFile); err == nil {
+		table, err = computeBlockSums(localFile)
+		if err != nil {
+			fatal(fmt.Sprintf("blocksums failed for %s", relPath), err)
+		}
+	}
+
+	var tableBuf bytes.Buffer
+	if err := writeBlockSums(&tableBuf, table); err != nil {
+		fatal("Encode blocksums failed", err)
+	}
+
+	remoteFile := remotePath + "/" + relPath
+	sess, err := client.NewSession()
+	if err != nil {
+		fatal("Session failed", err)
+	}
+	sess.Stdin = &tableBuf
+	var instrOut bytes.Buffer
+	sess.Stdout = &instrOut
+	if err := sess.Run(fmt.Sprintf("sshx-stream --gendiff \"%s\"", remoteFile)); err != nil {
+		sess.Close()
+		fatal(fmt.Sprintf("gendiff failed for %s", relPath), err)
+	}
+	sess.Close()
+
+	matched, literal, err := applyInstructions(localFile, &instrOut)
+	if err != nil {
+		fatal(fmt.Sprintf("apply failed for %s", relPath), err)
+	}
+
+	fmt.Printf("📄 %-40s matched=%d literal=%d\n", relPath, matched, literal)
+}
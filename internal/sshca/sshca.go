@@ -0,0 +1,239 @@
+// Package sshca implements the CA half of the module's certificate-based
+// auth: generating the CA keypair, signing short-lived user certificates,
+// and trusting the CA on a server. cmd/sshx-ca is a thin CLI over this
+// package; sshx-cpy's --cert flag uses it directly so both share one
+// signing/trust implementation.
+package sshca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	privateKeyName = "ca"
+	publicKeyName  = "ca.pub"
+	sshdCAFile     = "/etc/ssh/sshx_ca.pub"
+	sshdConfig     = "/etc/ssh/sshd_config"
+	trustedLine    = "TrustedUserCAKeys " + sshdCAFile
+)
+
+// Dir is where the CA keypair lives.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sshx", "ca"), nil
+}
+
+// PublicKeyPath returns the path to the CA's public key.
+func PublicKeyPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, publicKeyName), nil
+}
+
+func privateKeyPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, privateKeyName), nil
+}
+
+// Initialized reports whether the CA keypair already exists.
+func Initialized() bool {
+	path, err := privateKeyPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Init generates a fresh ed25519 CA keypair. It refuses to overwrite an
+// existing one.
+func Init() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	privPath, err := privateKeyPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(privPath); err == nil {
+		return fmt.Errorf("CA already initialized at %s", privPath)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "sshx-ca")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	pubPath, err := PublicKeyPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(sshPub), 0644)
+}
+
+// LoadSigner loads the CA's private key as an ssh.Signer, for signing
+// certificates.
+func LoadSigner() (ssh.Signer, error) {
+	path, err := privateKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA key (run 'sshx-ca init' first): %w", err)
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// Sign reads the public key at userPubPath and returns a signed OpenSSH
+// user certificate for the given principals, valid for ttl starting now.
+// It writes the certificate to "<userPubPath minus .pub>-cert.pub" and
+// returns that path.
+func Sign(userPubPath string, principals []string, ttl time.Duration) (string, error) {
+	caSigner, err := LoadSigner()
+	if err != nil {
+		return "", err
+	}
+
+	pubBytes, err := os.ReadFile(userPubPath)
+	if err != nil {
+		return "", fmt.Errorf("read user public key: %w", err)
+	}
+	userPub, _, _, _, err := ssh.ParseAuthorizedKey(pubBytes)
+	if err != nil {
+		return "", fmt.Errorf("parse user public key: %w", err)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             userPub,
+		Serial:          uint64(now.Unix()),
+		CertType:        ssh.UserCert,
+		KeyId:           filepath.Base(strings.TrimSuffix(userPubPath, ".pub")),
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":             "",
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		return "", fmt.Errorf("sign certificate: %w", err)
+	}
+
+	outPath := strings.TrimSuffix(userPubPath, ".pub") + "-cert.pub"
+	if err := os.WriteFile(outPath, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
+
+// LoadCertSigner builds an ssh.Signer that presents certPath alongside
+// keySigner during public-key auth, for clients authenticating with a
+// CA-issued certificate instead of a bare key.
+func LoadCertSigner(certPath string, keySigner ssh.Signer) (ssh.Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certPath)
+	}
+	return ssh.NewCertSigner(cert, keySigner)
+}
+
+// InstallTrust installs the CA's public key on the server behind client as
+// /etc/ssh/sshx_ca.pub, adds the matching TrustedUserCAKeys directive to
+// sshd_config if it's missing, and validates the result with `sshd -t`.
+// It does not restart sshd -- callers decide whether/when to do that.
+//
+// esc controls how the privileged writes under /etc/ssh run: nil means
+// client is already root, otherwise every write goes through sudo on the
+// same connection esc was primed on (see sshclient.Escalation).
+func InstallTrust(client *sshclient.Client, esc *sshclient.Escalation) error {
+	pubPath, err := PublicKeyPath()
+	if err != nil {
+		return err
+	}
+	caPub, err := os.ReadFile(pubPath)
+	if err != nil {
+		return fmt.Errorf("read CA public key (run 'sshx-ca init' first): %w", err)
+	}
+
+	if err := client.CopySudo(esc, strings.NewReader(string(caPub)), sshdCAFile); err != nil {
+		return fmt.Errorf("install CA public key: %w", err)
+	}
+	if err := client.RunSudo(esc, fmt.Sprintf("chmod 644 %s", sshdCAFile)); err != nil {
+		return fmt.Errorf("chmod CA public key: %w", err)
+	}
+
+	if err := ensureTrustedUserCAKeys(client, esc); err != nil {
+		return fmt.Errorf("update sshd_config: %w", err)
+	}
+
+	// sshd -t needs to read root-only host key files referenced in
+	// sshd_config, so this has to run escalated too on a non-root target.
+	if err := client.RunSudo(esc, "sshd -t"); err != nil {
+		return fmt.Errorf("sshd -t reported a config error: %w", err)
+	}
+	return nil
+}
+
+func ensureTrustedUserCAKeys(client *sshclient.Client, esc *sshclient.Escalation) error {
+	existing, err := client.Output(fmt.Sprintf("cat %s 2>/dev/null", sshdConfig))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(existing, "\n") {
+		if strings.TrimSpace(line) == trustedLine {
+			return nil
+		}
+	}
+
+	updated := strings.TrimRight(existing, "\n") + "\n" + trustedLine + "\n"
+	return client.CopySudo(esc, strings.NewReader(updated), sshdConfig)
+}
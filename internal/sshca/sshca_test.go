@@ -0,0 +1,126 @@
+package sshca
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// withTempHome points $HOME (and therefore Dir()) at a scratch directory
+// for the duration of the test.
+func withTempHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return home
+}
+
+func TestInitCreatesKeypair(t *testing.T) {
+	withTempHome(t)
+
+	if Initialized() {
+		t.Fatal("Initialized() = true before Init")
+	}
+	if err := Init(); err != nil {
+		t.Fatalf("Init() = %v", err)
+	}
+	if !Initialized() {
+		t.Fatal("Initialized() = false after Init")
+	}
+	if _, err := LoadSigner(); err != nil {
+		t.Errorf("LoadSigner() after Init = %v", err)
+	}
+}
+
+func TestInitRefusesToOverwrite(t *testing.T) {
+	withTempHome(t)
+
+	if err := Init(); err != nil {
+		t.Fatalf("first Init() = %v", err)
+	}
+	if err := Init(); err == nil {
+		t.Fatal("second Init() = nil error, want refusal to overwrite")
+	}
+}
+
+func TestSignProducesValidCertificate(t *testing.T) {
+	home := withTempHome(t)
+	if err := Init(); err != nil {
+		t.Fatalf("Init() = %v", err)
+	}
+
+	_, userPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate user key: %v", err)
+	}
+	userSigner, err := ssh.NewSignerFromKey(userPriv)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey: %v", err)
+	}
+
+	pubPath := filepath.Join(home, "user.pub")
+	if err := os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(userSigner.PublicKey()), 0644); err != nil {
+		t.Fatalf("write user pubkey: %v", err)
+	}
+
+	certPath, err := Sign(pubPath, []string{"alice", "root"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Sign() = %v", err)
+	}
+	if want := filepath.Join(home, "user-cert.pub"); certPath != want {
+		t.Errorf("Sign() path = %q, want %q", certPath, want)
+	}
+
+	certSigner, err := LoadCertSigner(certPath, userSigner)
+	if err != nil {
+		t.Fatalf("LoadCertSigner() = %v", err)
+	}
+	cert, ok := certSigner.PublicKey().(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("certSigner.PublicKey() = %T, want *ssh.Certificate", certSigner.PublicKey())
+	}
+
+	if got, want := cert.ValidPrincipals, []string{"alice", "root"}; !stringSlicesEqual(got, want) {
+		t.Errorf("ValidPrincipals = %v, want %v", got, want)
+	}
+	if cert.CertType != ssh.UserCert {
+		t.Errorf("CertType = %v, want UserCert", cert.CertType)
+	}
+	if cert.ValidBefore <= cert.ValidAfter {
+		t.Errorf("ValidBefore (%d) <= ValidAfter (%d), want a positive ttl window", cert.ValidBefore, cert.ValidAfter)
+	}
+
+	caPubPath, err := PublicKeyPath()
+	if err != nil {
+		t.Fatalf("PublicKeyPath() = %v", err)
+	}
+	caPubBytes, err := os.ReadFile(caPubPath)
+	if err != nil {
+		t.Fatalf("read CA pubkey: %v", err)
+	}
+	caPub, _, _, _, err := ssh.ParseAuthorizedKey(caPubBytes)
+	if err != nil {
+		t.Fatalf("parse CA pubkey: %v", err)
+	}
+	if !bytes.Equal(cert.SignatureKey.Marshal(), caPub.Marshal()) {
+		t.Error("certificate's SignatureKey does not match the CA's public key")
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
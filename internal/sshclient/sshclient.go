@@ -0,0 +1,410 @@
+// Package sshclient is the shared native SSH transport used by every tool
+// in this module (sshx-cpy, sshx-stream, git-auth, ...). It wraps
+// golang.org/x/crypto/ssh so tools stop shelling out to the system `ssh`
+// binary and parsing its stdout/stderr.
+package sshclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Client wraps an established SSH connection.
+type Client struct {
+	*ssh.Client
+}
+
+// Session wraps a single SSH session channel.
+type Session struct {
+	*ssh.Session
+}
+
+// Dial opens a native SSH connection to host:port as user, trying each auth
+// method in order until one succeeds.
+func Dial(user, host, port string, auth ...ssh.AuthMethod) (*Client, error) {
+	hostKeyCB, err := hostKeyCallback()
+	if err != nil {
+		return nil, fmt.Errorf("host key verification: %w", err)
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCB,
+		Timeout:         dialTimeout,
+	}
+
+	addr := net.JoinHostPort(host, port)
+	conn, err := ssh.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	return &Client{conn}, nil
+}
+
+// NewSession opens a new session channel over the existing connection.
+// Unlike shelling out, this does not spawn a new process or TCP connection
+// per call -- sessions are cheap channels multiplexed over one link.
+func (c *Client) NewSession() (*Session, error) {
+	sess, err := c.Client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{sess}, nil
+}
+
+// Run executes cmd on the remote host and discards its output.
+func (c *Client) Run(cmd string) error {
+	sess, err := c.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	return sess.Run(cmd)
+}
+
+// Output executes cmd on the remote host and returns its combined
+// stdout+stderr, trimmed of trailing whitespace is left to the caller.
+func (c *Client) Output(cmd string) (string, error) {
+	sess, err := c.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer sess.Close()
+
+	var out bytes.Buffer
+	sess.Stdout = &out
+	sess.Stderr = &out
+	err = sess.Run(cmd)
+	return out.String(), err
+}
+
+// SFTP opens an SFTP client over the existing connection.
+func (c *Client) SFTP() (*sftp.Client, error) {
+	return sftp.NewClient(c.Client)
+}
+
+// Copy streams r to the remote path dst using SFTP, creating/truncating it.
+func (c *Client) Copy(r io.Reader, dst string) error {
+	sc, err := c.SFTP()
+	if err != nil {
+		return fmt.Errorf("open sftp: %w", err)
+	}
+	defer sc.Close()
+
+	f, err := sc.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// CopyFrom streams the remote path src into w using SFTP.
+func (c *Client) CopyFrom(w io.Writer, src string) error {
+	sc, err := c.SFTP()
+	if err != nil {
+		return fmt.Errorf("open sftp: %w", err)
+	}
+	defer sc.Close()
+
+	f, err := sc.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+////////////////////////////////////////////////////////////
+// Sudo escalation: run privileged ops over an already-authenticated
+// connection, never embedding a password in a remote command string.
+////////////////////////////////////////////////////////////
+
+// Escalation carries how to run privileged operations as a non-root user
+// on an existing connection. An empty Password means passwordless sudo
+// (`sudo -n`); a non-empty one is fed through `sudo -S`'s stdin pipe.
+// A nil *Escalation means "run unprivileged" -- RunSudo/CopySudo fall
+// back to Run/Copy.
+type Escalation struct {
+	Password string
+}
+
+// RunSudo runs cmd as root via sudo over c, the same connection the
+// escalation's password (if any) was primed on.
+func (c *Client) RunSudo(e *Escalation, cmd string) error {
+	if e == nil {
+		if out, err := c.Output(cmd); err != nil {
+			return fmt.Errorf("%s: %s: %w", cmd, strings.TrimSpace(out), err)
+		}
+		return nil
+	}
+
+	sess, err := c.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	sess.Stdout = &out
+	sess.Stderr = &out
+
+	if err := sess.Start(sudoCommand(e, "sh -c "+shQuote(cmd))); err != nil {
+		return err
+	}
+	if e.Password != "" {
+		if _, err := stdin.Write([]byte(e.Password + "\n")); err != nil {
+			return err
+		}
+	}
+	stdin.Close()
+
+	if err := sess.Wait(); err != nil {
+		return fmt.Errorf("sudo %s: %s: %w", cmd, strings.TrimSpace(out.String()), err)
+	}
+	return nil
+}
+
+// CopySudo streams r to the remote path dst as root via `sudo tee`. SFTP
+// writes can't be escalated after the fact, so a privileged copy goes
+// through a root-owned shell pipe instead of the SFTP subsystem.
+func (c *Client) CopySudo(e *Escalation, r io.Reader, dst string) error {
+	if e == nil {
+		return c.Copy(r, dst)
+	}
+
+	sess, err := c.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	sess.Stdout = &out
+	sess.Stderr = &out
+
+	if err := sess.Start(sudoCommand(e, "tee "+shQuote(dst)+" >/dev/null")); err != nil {
+		return err
+	}
+	if e.Password != "" {
+		if _, err := stdin.Write([]byte(e.Password + "\n")); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(stdin, r); err != nil {
+		return err
+	}
+	stdin.Close()
+
+	if err := sess.Wait(); err != nil {
+		return fmt.Errorf("sudo tee %s: %s: %w", dst, strings.TrimSpace(out.String()), err)
+	}
+	return nil
+}
+
+// sudoCommand wraps inner in a sudo invocation appropriate for e: -n for
+// passwordless sudo, -S -p '' when a password will be piped through stdin.
+func sudoCommand(e *Escalation, inner string) string {
+	if e.Password == "" {
+		return "sudo -n " + inner
+	}
+	return "sudo -S -p '' " + inner
+}
+
+// shQuote single-quotes s for safe inclusion as one argument in a remote
+// shell command, escaping any embedded single quotes.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+////////////////////////////////////////////////////////////
+// Host key verification: ~/.ssh/known_hosts, trust-on-first-use
+////////////////////////////////////////////////////////////
+
+// hostKeyCallback returns a knownhosts-backed ssh.HostKeyCallback using
+// ~/.ssh/known_hosts, with the same trust-on-first-use semantics as the
+// system ssh client's StrictHostKeyChecking=accept-new: an unseen host is
+// pinned on first connect, while a key that has changed for an
+// already-known host is rejected rather than silently accepted.
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	path, err := knownHostsPath()
+	if err != nil {
+		return nil, fmt.Errorf("resolve known_hosts path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create ssh dir: %w", err)
+	}
+	if f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND, 0o600); err != nil {
+		return nil, fmt.Errorf("create known_hosts: %w", err)
+	} else {
+		f.Close()
+	}
+
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			// Host key not found, rather than found-but-different: TOFU-pin it.
+			return pinHostKey(path, hostname, key)
+		}
+		return fmt.Errorf("host key verification failed for %s: %w", hostname, err)
+	}, nil
+}
+
+// pinHostKey appends hostname's key to known_hosts, the same way the
+// system ssh client does on first connect under accept-new.
+func pinHostKey(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open known_hosts: %w", err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+func knownHostsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+////////////////////////////////////////////////////////////
+// Auth resolution: ssh-agent, key files, ~/.ssh/config
+////////////////////////////////////////////////////////////
+
+// ResolveAuth builds the ordered list of auth methods for host, the same
+// way the `ssh` binary would: an agent at SSH_AUTH_SOCK first (it may hold
+// keys not on disk), then identity files, with paths resolved against
+// ~/.ssh/config's IdentityFile/HostName/User/Port directives.
+func ResolveAuth(host string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if am, err := agentAuth(); err == nil {
+		methods = append(methods, am)
+	}
+
+	for _, path := range identityFiles(host) {
+		if am, err := keyFileAuth(path); err == nil {
+			methods = append(methods, am)
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no usable auth method (no agent, no identity file) for host %q", host)
+	}
+	return methods, nil
+}
+
+// ResolveTarget applies ~/.ssh/config HostName/User/Port overrides for an
+// alias, returning the effective host, user (may be empty) and port.
+func ResolveTarget(alias string) (host, user, port string) {
+	host = ssh_config.Get(alias, "HostName")
+	if host == "" {
+		host = alias
+	}
+	user = ssh_config.Get(alias, "User")
+	port = ssh_config.Get(alias, "Port")
+	if port == "" {
+		port = "22"
+	}
+	return host, user, port
+}
+
+func agentAuth() (ssh.AuthMethod, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, fmt.Errorf("SSH_AUTH_SOCK not set")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	ag := agent.NewClient(conn)
+	return ssh.PublicKeysCallback(ag.Signers), nil
+}
+
+func keyFileAuth(path string) (ssh.AuthMethod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// identityFiles returns the candidate private key paths for host, honoring
+// ~/.ssh/config's IdentityFile directive and falling back to the default
+// id_ed25519/id_rsa pair.
+func identityFiles(host string) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	sshDir := filepath.Join(home, ".ssh")
+
+	var files []string
+	if ids := ssh_config.GetAll(host, "IdentityFile"); len(ids) > 0 {
+		for _, id := range ids {
+			files = append(files, expandPath(id, home))
+		}
+		return files
+	}
+
+	for _, name := range []string{"id_ed25519", "id_rsa"} {
+		files = append(files, filepath.Join(sshDir, name))
+	}
+	return files
+}
+
+func expandPath(path, home string) string {
+	if len(path) > 0 && path[0] == '~' {
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
@@ -0,0 +1,46 @@
+package sshclient
+
+import "testing"
+
+func TestSudoCommandPasswordless(t *testing.T) {
+	got := sudoCommand(&Escalation{}, "sshd -t")
+	want := "sudo -n sshd -t"
+	if got != want {
+		t.Errorf("sudoCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestSudoCommandPrimedPassword(t *testing.T) {
+	got := sudoCommand(&Escalation{Password: "hunter2"}, "sshd -t")
+	want := "sudo -S -p '' sshd -t"
+	if got != want {
+		t.Errorf("sudoCommand() = %q, want %q", got, want)
+	}
+	if containsPassword(got, "hunter2") {
+		t.Errorf("sudoCommand() embedded the password in the remote command: %q", got)
+	}
+}
+
+func TestShQuoteEscapesEmbeddedQuotes(t *testing.T) {
+	cases := map[string]string{
+		"plain":      "'plain'",
+		"":           "''",
+		"it's":       `'it'\''s'`,
+		"a'b'c":      `'a'\''b'\''c'`,
+		"; rm -rf /": "'; rm -rf /'",
+	}
+	for in, want := range cases {
+		if got := shQuote(in); got != want {
+			t.Errorf("shQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func containsPassword(s, password string) bool {
+	for i := 0; i+len(password) <= len(s); i++ {
+		if s[i:i+len(password)] == password {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+// Package sshops implements the operations sshx-serve exposes as remote
+// subsystems: cleanup, stream push/pull, and run. It exists so sshx-serve
+// can call the module's tools' logic directly as Go functions instead of
+// re-execing a shell per request.
+package sshops
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Cleanup mirrors sshx-reset's smartSSHCleanup, writing its report to w
+// instead of stdout so a remote caller can see it.
+func Cleanup(w io.Writer) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	sshPath := filepath.Join(home, ".ssh")
+
+	fmt.Fprintln(w, "Starting Professional SSH Environment Cleanup...")
+	fmt.Fprintln(w, "--------------------------------------------------")
+
+	protected := map[string]bool{
+		filepath.Join(sshPath, "id_ed25519"):      true,
+		filepath.Join(sshPath, "id_ed25519.pub"):  true,
+		filepath.Join(sshPath, "authorized_keys"): true,
+	}
+
+	patterns := []string{"*.old", "*.tmp", "*.bak", "known_hosts"}
+	var filesToClean []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(sshPath, pattern))
+		filesToClean = append(filesToClean, matches...)
+	}
+
+	cleaned := 0
+	for _, file := range filesToClean {
+		if protected[file] {
+			continue
+		}
+		if _, err := os.Stat(file); err == nil {
+			if err := os.Remove(file); err == nil {
+				fmt.Fprintln(w, "Removed:", filepath.Base(file))
+				cleaned++
+			} else {
+				fmt.Fprintln(w, "Error removing:", filepath.Base(file), "-", err)
+			}
+		}
+	}
+
+	hostsPath := filepath.Join(sshPath, "known_hosts")
+	if f, err := os.Create(hostsPath); err == nil {
+		f.Close()
+		os.Chmod(hostsPath, 0600)
+		fmt.Fprintln(w, "\nknown_hosts has been securely reset.")
+	}
+
+	fmt.Fprintln(w, "--------------------------------------------------")
+	fmt.Fprintln(w, "Cleanup complete! Total junk files removed:", cleaned)
+	return nil
+}
+
+// StreamPush reads a tar.gz stream from r and extracts it under dst.
+func StreamPush(dst string, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar entry: %w", err)
+		}
+
+		target, err := safeJoin(dst, hdr.Name)
+		if err != nil {
+			return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// safeJoin joins dst with a tar entry name, rejecting names that would
+// resolve outside dst (absolute paths, "../" escapes) -- a malicious
+// entry must not be able to write anywhere else on the receiver's disk.
+func safeJoin(dst, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("absolute path not allowed: %s", name)
+	}
+	target := filepath.Join(dst, name)
+	rel, err := filepath.Rel(dst, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("escapes destination: %s", name)
+	}
+	return target, nil
+}
+
+// StreamPull tars and gzips src, writing the stream to w.
+func StreamPull(src string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// Run executes argv locally, streaming its output to stdout/stderr.
+func Run(argv []string, stdout, stderr io.Writer) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("no command given")
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
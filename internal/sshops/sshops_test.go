@@ -0,0 +1,94 @@
+package sshops
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoinRejectsEscapes(t *testing.T) {
+	cases := []string{
+		"../outside",
+		"a/../../outside",
+		"/etc/passwd",
+	}
+	for _, name := range cases {
+		if _, err := safeJoin("/dst", name); err == nil {
+			t.Errorf("safeJoin(%q) = nil error, want rejection", name)
+		}
+	}
+}
+
+func TestSafeJoinAllowsWithinDest(t *testing.T) {
+	got, err := safeJoin("/dst", "a/b/c.txt")
+	if err != nil {
+		t.Fatalf("safeJoin: %v", err)
+	}
+	if want := filepath.Join("/dst", "a/b/c.txt"); got != want {
+		t.Errorf("safeJoin() = %q, want %q", got, want)
+	}
+}
+
+func TestStreamPushRejectsTarSlip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "../escaped.txt",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len("pwned")),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("pwned")); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+
+	dst := t.TempDir()
+	if err := StreamPush(dst, &buf); err == nil {
+		t.Fatal("StreamPush succeeded on a path-traversal entry, want error")
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "escaped.txt")); err == nil {
+		t.Fatal("tar-slip entry was written outside dst")
+	}
+}
+
+func TestStreamPushExtractsWithinDest(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("hello")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "sub/file.txt",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(content)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gz.Close()
+
+	dst := t.TempDir()
+	if err := StreamPush(dst, &buf); err != nil {
+		t.Fatalf("StreamPush: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dst, "sub/file.txt"))
+	if err != nil {
+		t.Fatalf("read extracted file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("extracted content = %q, want %q", got, content)
+	}
+}
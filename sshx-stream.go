@@ -16,6 +16,8 @@ import (
 	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/tradeguruboffin-commits/esey-ssh-dev/internal/sshclient"
 )
 
 const (
@@ -43,20 +45,49 @@ func fatal(msg string, err error) {
 	os.Exit(1)
 }
 
+// SSHFunc runs a remote command over the persistent connection and returns
+// its trimmed combined output.
 type SSHFunc func(cmd string) (string, error)
 
 func main() {
-	if len(os.Args) != 5 {
+	// Remote worker entry points: the other end of a delta push/pull
+	// invokes us in-process over SSH as `sshx-stream --flag <path>`.
+	if len(os.Args) == 3 {
+		switch os.Args[1] {
+		case "--blocksums":
+			cmdBlockSums(os.Args[2])
+			return
+		case "--apply-delta":
+			cmdApplyDelta(os.Args[2])
+			return
+		case "--gendiff":
+			cmdGenDiff(os.Args[2])
+			return
+		}
+	}
+
+	var full bool
+	var rest []string
+	for _, a := range os.Args[1:] {
+		if a == "--full" {
+			full = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+
+	if len(rest) != 4 {
 		fmt.Println("Usage:")
-		fmt.Println("  sshx-stream push user@host:port <local_dir> <remote_path>")
-		fmt.Println("  sshx-stream pull user@host:port <remote_path> <local_dir>")
+		fmt.Println("  sshx-stream push [--full] user@host:port <local_dir> <remote_path>")
+		fmt.Println("  sshx-stream pull [--full] user@host:port <remote_path> <local_dir>")
+		fmt.Println("(--full re-tars the whole tree; by default a rolling-checksum delta is sent)")
 		os.Exit(1)
 	}
 
-	mode := os.Args[1]
-	target := os.Args[2]
-	localPath := os.Args[3]
-	remotePath := os.Args[4]
+	mode := rest[0]
+	target := rest[1]
+	localPath := rest[2]
+	remotePath := rest[3]
 
 	if !strings.Contains(target, "@") || !strings.Contains(target, ":") {
 		fatal("Invalid target format", nil)
@@ -70,7 +101,6 @@ func main() {
 	}
 	host := hostParts[0]
 	port := hostParts[1]
-	sshSocket := fmt.Sprintf("/tmp/sshx_mux_%s_%s_%s", user, host, port)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -83,41 +113,44 @@ func main() {
 		cancel()
 	}()
 
+	auth, err := sshclient.ResolveAuth(host)
+	if err != nil {
+		fatal("No usable SSH credentials", err)
+	}
+
+	client, err := sshclient.Dial(user, host, port, auth...)
+	if err != nil {
+		fatal("SSH connection failed", err)
+	}
+	defer client.Close()
+
+	// One connection, many cheap session channels -- no more spawning a
+	// fresh `ssh`/`dd` process pair per chunk.
 	sshCmd := func(cmd string) (string, error) {
-		out, err := exec.Command("ssh",
-			"-p", port,
-			"-S", sshSocket,
-			user+"@"+host,
-			cmd).CombinedOutput()
-		return strings.TrimSpace(string(out)), err
-	}
-
-	if err := exec.Command("ssh",
-		"-p", port,
-		"-o", "ControlMaster=yes",
-		"-o", "ControlPersist=600",
-		"-o", "ControlPath="+sshSocket,
-		"-fN",
-		user+"@"+host).Run(); err != nil {
-		fatal("SSH multiplex failed", err)
-	}
-
-	defer func() {
-		exec.Command("ssh", "-S", sshSocket, "-O", "exit", user+"@"+host).Run()
-	}()
+		out, err := client.Output(cmd)
+		return strings.TrimSpace(out), err
+	}
 
 	switch mode {
 	case "push":
-		push(ctx, localPath, remotePath, user, host, port, sshSocket, sshCmd)
+		if full {
+			tarPush(ctx, localPath, remotePath, client, sshCmd)
+		} else {
+			deltaPushTree(ctx, localPath, remotePath, client, sshCmd)
+		}
 	case "pull":
-		pull(ctx, localPath, remotePath, user, host, port, sshSocket, sshCmd)
+		if full {
+			tarPull(ctx, localPath, remotePath, client, sshCmd)
+		} else {
+			deltaPullTree(ctx, remotePath, localPath, client, sshCmd)
+		}
 	default:
 		fatal("Mode must be push or pull", nil)
 	}
 }
 
-// ---------------- Push ----------------
-func push(ctx context.Context, localDir, remotePath, user, host, port, sshSocket string, sshCmd SSHFunc) {
+// ---------------- Push (--full: tar the whole tree) ----------------
+func tarPush(ctx context.Context, localDir, remotePath string, client *sshclient.Client, sshCmd SSHFunc) {
 	if _, err := os.Stat(localDir); os.IsNotExist(err) {
 		fatal("Directory not found", nil)
 	}
@@ -176,17 +209,20 @@ func push(ctx context.Context, localDir, remotePath, user, host, port, sshSocket
 					localHash := sha256Bytes(chunk.Data)
 					success := false
 					for r := 0; r < MaxRetry; r++ {
-						cmd := exec.CommandContext(ctx, "ssh",
-							"-p", port,
-							"-S", sshSocket,
-							user+"@"+host,
-							fmt.Sprintf("dd of=\"%s/.sshx_partial.tar.gz\" bs=%d seek=%d conv=notrunc",
-								remotePath, ChunkSize, chunk.Index))
-						cmd.Stdin = bytes.NewReader(chunk.Data)
-						if err := cmd.Run(); err != nil {
-							if ctx.Err() != nil {
-								return
-							}
+						if ctx.Err() != nil {
+							return
+						}
+
+						sess, err := client.NewSession()
+						if err != nil {
+							continue
+						}
+						sess.Stdin = bytes.NewReader(chunk.Data)
+						runErr := sess.Run(fmt.Sprintf(
+							"dd of=\"%s/.sshx_partial.tar.gz\" bs=%d seek=%d conv=notrunc",
+							remotePath, ChunkSize, chunk.Index))
+						sess.Close()
+						if runErr != nil {
 							continue
 						}
 
@@ -265,8 +301,8 @@ ProducerLoop:
 	fmt.Println("✅ Push completed")
 }
 
-// ---------------- Pull ----------------
-func pull(ctx context.Context, localDir, remotePath, user, host, port, sshSocket string, sshCmd SSHFunc) {
+// ---------------- Pull (--full: tar the whole tree) ----------------
+func tarPull(ctx context.Context, localDir, remotePath string, client *sshclient.Client, sshCmd SSHFunc) {
 	if _, err := os.Stat(localDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(localDir, 0755); err != nil {
 			fatal("Cannot create local directory", err)
@@ -343,18 +379,24 @@ done
 					success := false
 					var chunkData []byte
 					for r := 0; r < MaxRetry; r++ {
-						out, err := exec.CommandContext(ctx, "ssh",
-							"-p", port,
-							"-S", sshSocket,
-							user+"@"+host,
-							fmt.Sprintf("dd if=\"%s\" bs=%d skip=%d count=1 2>/dev/null", remoteTmp, ChunkSize, idx)).Output()
+						if ctx.Err() != nil {
+							return
+						}
+
+						sess, err := client.NewSession()
 						if err != nil {
-							if ctx.Err() != nil {
-								return
-							}
+							continue
+						}
+						var buf bytes.Buffer
+						sess.Stdout = &buf
+						runErr := sess.Run(fmt.Sprintf(
+							"dd if=\"%s\" bs=%d skip=%d count=1 2>/dev/null", remoteTmp, ChunkSize, idx))
+						sess.Close()
+						if runErr != nil {
 							continue
 						}
 
+						out := buf.Bytes()
 						if sha256Bytes(out) == strings.TrimSpace(remoteHashes[idx-startChunk]) {
 							chunkData = out
 							success = true